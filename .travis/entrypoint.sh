@@ -4,9 +4,11 @@ export PATH=$PWD/bin:/usr/local/go/bin:$PATH
 export GOPATH=$PWD:/usr/local/go
 
 roscore &
+go get gopkg.in/yaml.v3
 go install github.com/fetchrobotics/rosgo/gengo
 go generate github.com/fetchrobotics/rosgo/test/test_message
 go test github.com/fetchrobotics/rosgo/xmlrpc
 go test github.com/fetchrobotics/rosgo/ros
+go test github.com/fetchrobotics/rosgo/message_filters
 go test github.com/fetchrobotics/rosgo/test/test_message
 
@@ -3,6 +3,8 @@ package main
 import (
 	"bytes"
 	"text/template"
+
+	"github.com/fetchrobotics/rosgo/msgs"
 )
 
 var msgTemplate = `
@@ -370,7 +372,7 @@ func (s *{{ .ShortName }}) GetActionResult() actionlib.ActionResult     { return
 `
 
 type MsgGen struct {
-	MsgSpec
+	msgs.MsgSpec
 	BinaryRequired bool
 	IsAction       bool
 	Imports        []string
@@ -405,7 +407,7 @@ LOOP:
 	}
 }
 
-func GenerateMessage(context *MsgContext, spec *MsgSpec, isAction bool) (string, error) {
+func GenerateMessage(context *msgs.MsgContext, spec *msgs.MsgSpec, isAction bool) (string, error) {
 	var gen MsgGen
 	gen.IsAction = isAction
 	gen.Fields = spec.Fields
@@ -432,7 +434,7 @@ func GenerateMessage(context *MsgContext, spec *MsgSpec, isAction bool) (string,
 	return buffer.String(), err
 }
 
-func GenerateService(context *MsgContext, spec *SrvSpec) (string, string, string, error) {
+func GenerateService(context *msgs.MsgContext, spec *msgs.SrvSpec) (string, string, string, error) {
 	reqCode, err := GenerateMessage(context, spec.Request, false)
 	if err != nil {
 		return "", "", "", err
@@ -460,7 +462,7 @@ type ActionCode struct {
 	goalCode string
 }
 
-func GenerateAction(context *MsgContext, spec *ActionSpec) (actionCode string, codeMap map[string]string, err error) {
+func GenerateAction(context *msgs.MsgContext, spec *msgs.ActionSpec) (actionCode string, codeMap map[string]string, err error) {
 	codeMap = make(map[string]string)
 	codeMap[spec.Goal.FullName], err = GenerateMessage(context, spec.Goal, false)
 	if err != nil {
@@ -7,6 +7,8 @@ import (
 	"os"
 	"strings"
 	"testing"
+
+	"github.com/fetchrobotics/rosgo/msgs"
 )
 
 func TestGenerateBadAction(t *testing.T) {
@@ -42,7 +44,7 @@ string[] sva
 string[42] sfa
 `
 	rosPkgPath := os.Getenv("ROS_PACKAGE_PATH")
-	ctx, e := NewMsgContext(strings.Split(rosPkgPath, ":"))
+	ctx, e := msgs.NewMsgContext(strings.Split(rosPkgPath, ":"))
 	if e != nil {
 		t.Errorf("Failed to create MsgContext.")
 	}
@@ -94,12 +96,12 @@ Bar[] xva
 Bar[42] xfa
 `
 	rosPkgPath := os.Getenv("ROS_PACKAGE_PATH")
-	ctx, e := NewMsgContext(strings.Split(rosPkgPath, ":"))
+	ctx, e := msgs.NewMsgContext(strings.Split(rosPkgPath, ":"))
 	if e != nil {
 		t.Errorf("Failed to create MsgContext.")
 	}
 
-	var spec *ActionSpec
+	var spec *msgs.ActionSpec
 	spec, e = ctx.LoadActionFromString(text, "foo/Foo")
 	if e != nil {
 		t.Errorf("Failed to parse: %v", e)
@@ -151,12 +153,12 @@ Bar[] xva
 Bar[42] xfa
 `
 	rosPkgPath := os.Getenv("ROS_PACKAGE_PATH")
-	ctx, e := NewMsgContext(strings.Split(rosPkgPath, ":"))
+	ctx, e := msgs.NewMsgContext(strings.Split(rosPkgPath, ":"))
 	if e != nil {
 		t.Errorf("Failed to create MsgContext.")
 	}
 
-	var spec *MsgSpec
+	var spec *msgs.MsgSpec
 	spec, e = ctx.LoadMsgFromString(text, "foo/Foo")
 	if e != nil {
 		t.Errorf("Failed to parse: %v", e)
@@ -8,6 +8,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/fetchrobotics/rosgo/msgs"
 )
 
 var (
@@ -51,7 +53,7 @@ func main() {
 
 	rosPkgPath := os.Getenv("ROS_PACKAGE_PATH")
 
-	context, err := NewMsgContext(strings.Split(rosPkgPath, ":"))
+	context, err := msgs.NewMsgContext(strings.Split(rosPkgPath, ":"))
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(-1)
@@ -62,7 +64,7 @@ func main() {
 	fmt.Printf("Generating %v...", fullname)
 
 	if mode == "msg" {
-		var spec *MsgSpec
+		var spec *msgs.MsgSpec
 		var err error
 		if flag.NArg() == 2 {
 			spec, err = context.LoadMsg(fullname)
@@ -85,7 +87,7 @@ func main() {
 			os.Exit(-1)
 		}
 	} else if mode == "srv" {
-		var spec *SrvSpec
+		var spec *msgs.SrvSpec
 		var err error
 		if flag.NArg() == 2 {
 			spec, err = context.LoadSrv(fullname)
@@ -120,7 +122,7 @@ func main() {
 			os.Exit(-1)
 		}
 	} else if mode == "action" {
-		var spec *ActionSpec
+		var spec *msgs.ActionSpec
 		var err error
 
 		if len(os.Args) == 3 {
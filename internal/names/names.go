@@ -0,0 +1,28 @@
+// Package names holds the graph name helpers shared by the ros and master packages.
+package names
+
+import (
+	"strings"
+)
+
+const (
+	Sep      = "/"
+	GlobalNS = "/"
+)
+
+// Canonicalize removes sequential separators and a trailing separator.
+func Canonicalize(name string) string {
+	if name == "" || name == GlobalNS {
+		return name
+	}
+	components := []string{}
+	for _, word := range strings.Split(name, Sep) {
+		if len(word) > 0 {
+			components = append(components, word)
+		}
+	}
+	if name[0:1] == GlobalNS {
+		return GlobalNS + strings.Join(components, Sep)
+	}
+	return strings.Join(components, Sep)
+}
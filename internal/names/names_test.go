@@ -0,0 +1,23 @@
+package names
+
+import (
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	var tests = []struct {
+		name     string
+		expected string
+	}{
+		{"", ""},
+		{"/", "/"},
+		{"/foo//bar/", "/foo/bar"},
+		{"foo//bar///baz/", "foo/bar/baz"},
+		{"~foo//bar///baz/", "~foo/bar/baz"},
+	}
+	for _, test := range tests {
+		if result := Canonicalize(test.name); result != test.expected {
+			t.Errorf("%q: %q", test.name, result)
+		}
+	}
+}
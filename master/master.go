@@ -0,0 +1,515 @@
+// Package master implements the ROS Master and Parameter Server XML-RPC APIs
+// on top of the xmlrpc package, so rosgo nodes can run without an external roscore.
+package master
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fetchrobotics/rosgo/xmlrpc"
+)
+
+const (
+	apiStatusError   = -1
+	apiStatusFailure = 0
+	apiStatusSuccess = 1
+
+	// CallerID is used as the caller ID of the callbacks sent by the master.
+	CallerID = "/master"
+)
+
+// Build XMLRPC ready array from ROS API result triplet.
+func buildRosAPIResult(code int32, message string, value interface{}) interface{} {
+	return []interface{}{code, message, value}
+}
+
+type serviceRegistration struct {
+	callerID   string
+	serviceAPI string
+}
+
+// Master is an in-process ROS master and parameter server.
+type Master struct {
+	uri         string
+	listener    net.Listener
+	handler     *xmlrpc.Handler
+	mutex       sync.Mutex
+	nodes       map[string]string            // callerID -> callerAPI
+	publishers  map[string]map[string]string // topic -> callerID -> callerAPI
+	subscribers map[string]map[string]string // topic -> callerID -> callerAPI
+	topicTypes  map[string]string
+	services    map[string]serviceRegistration
+	params      *paramServer
+	notifier    *notifier
+}
+
+// NewMaster creates a master listening on the given TCP address (e.g. ":11311")
+// and starts serving the XML-RPC API.
+func NewMaster(address string) (*Master, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		host = determineHost()
+	}
+
+	m := new(Master)
+	m.uri = fmt.Sprintf("http://%s/", net.JoinHostPort(host, port))
+	m.listener = listener
+	m.nodes = make(map[string]string)
+	m.publishers = make(map[string]map[string]string)
+	m.subscribers = make(map[string]map[string]string)
+	m.topicTypes = make(map[string]string)
+	m.services = make(map[string]serviceRegistration)
+	m.params = newParamServer()
+	m.notifier = newNotifier()
+
+	methods := map[string]xmlrpc.Method{
+		"registerService":      m.registerService,
+		"unregisterService":    m.unregisterService,
+		"registerSubscriber":   m.registerSubscriber,
+		"unregisterSubscriber": m.unregisterSubscriber,
+		"registerPublisher":    m.registerPublisher,
+		"unregisterPublisher":  m.unregisterPublisher,
+		"lookupNode":           m.lookupNode,
+		"getPublishedTopics":   m.getPublishedTopics,
+		"getTopicTypes":        m.getTopicTypes,
+		"getSystemState":       m.getSystemState,
+		"getUri":               m.getURI,
+		"lookupService":        m.lookupService,
+		"getPid":               m.getPid,
+		"deleteParam":          m.deleteParam,
+		"setParam":             m.setParam,
+		"getParam":             m.getParam,
+		"searchParam":          m.searchParam,
+		"subscribeParam":       m.subscribeParam,
+		"unsubscribeParam":     m.unsubscribeParam,
+		"hasParam":             m.hasParam,
+		"getParamNames":        m.getParamNames,
+	}
+	m.handler = xmlrpc.NewHandler(methods)
+	go http.Serve(m.listener, m.handler)
+	return m, nil
+}
+
+// URI returns the XML-RPC URI of the master, suitable for ROS_MASTER_URI.
+func (m *Master) URI() string {
+	return m.uri
+}
+
+// Shutdown stops serving the API and waits for in-flight requests.
+func (m *Master) Shutdown() {
+	m.listener.Close()
+	m.handler.WaitForShutdown()
+	m.notifier.shutdown()
+}
+
+func determineHost() string {
+	if hostname := os.Getenv("ROS_HOSTNAME"); len(hostname) > 0 {
+		return hostname
+	}
+	if ip := os.Getenv("ROS_IP"); len(ip) > 0 {
+		return ip
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		return hostname
+	}
+	return "localhost"
+}
+
+// registerNode records the API of the node. If a node with the same name was
+// registered with a different API, the old node is shut down and all of its
+// registrations are dropped. Must be called with the mutex held.
+func (m *Master) registerNode(callerID string, callerAPI string) {
+	oldAPI, ok := m.nodes[callerID]
+	if ok && oldAPI != callerAPI {
+		m.dropNode(callerID)
+		m.notifier.notify(oldAPI, func() error {
+			_, err := xmlrpc.Call(oldAPI, "shutdown", CallerID, fmt.Sprintf("new node registered with same name [%s]", callerID))
+			return err
+		})
+	}
+	m.nodes[callerID] = callerAPI
+}
+
+// dropNode removes all the registrations of the node. Must be called with the mutex held.
+func (m *Master) dropNode(callerID string) {
+	for topic, nodes := range m.publishers {
+		if _, ok := nodes[callerID]; ok {
+			delete(nodes, callerID)
+			m.notifyPublisherUpdate(topic)
+		}
+		if len(nodes) == 0 {
+			delete(m.publishers, topic)
+		}
+	}
+	for topic, nodes := range m.subscribers {
+		delete(nodes, callerID)
+		if len(nodes) == 0 {
+			delete(m.subscribers, topic)
+		}
+	}
+	for service, reg := range m.services {
+		if reg.callerID == callerID {
+			delete(m.services, service)
+		}
+	}
+	m.params.unsubscribeNode(callerID)
+	delete(m.nodes, callerID)
+}
+
+// Forget a node which has no registrations left. Must be called with the mutex held.
+func (m *Master) cleanupNode(callerID string) {
+	for _, nodes := range m.publishers {
+		if _, ok := nodes[callerID]; ok {
+			return
+		}
+	}
+	for _, nodes := range m.subscribers {
+		if _, ok := nodes[callerID]; ok {
+			return
+		}
+	}
+	for _, reg := range m.services {
+		if reg.callerID == callerID {
+			return
+		}
+	}
+	if m.params.isSubscriber(callerID) {
+		return
+	}
+	delete(m.nodes, callerID)
+}
+
+func sortedValues(nodes map[string]string) []string {
+	values := []string{}
+	for _, v := range nodes {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	return values
+}
+
+func sortedKeys(nodes map[string]string) []string {
+	keys := []string{}
+	for k := range nodes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func toInterfaces(strs []string) []interface{} {
+	result := make([]interface{}, len(strs))
+	for i, s := range strs {
+		result[i] = s
+	}
+	return result
+}
+
+// Send publisherUpdate to every subscriber of the topic. Must be called with the mutex held.
+func (m *Master) notifyPublisherUpdate(topic string) {
+	publishers := toInterfaces(sortedValues(m.publishers[topic]))
+	for _, api := range m.subscribers[topic] {
+		subscriberAPI := api
+		m.notifier.notify(subscriberAPI, func() error {
+			_, err := xmlrpc.Call(subscriberAPI, "publisherUpdate", CallerID, topic, publishers)
+			return err
+		})
+	}
+}
+
+// Send paramUpdate callbacks. Must be called with the mutex held.
+func (m *Master) notifyParamUpdates(updates []paramUpdate) {
+	for _, u := range updates {
+		update := u
+		m.notifier.notify(update.callerAPI, func() error {
+			_, err := xmlrpc.Call(update.callerAPI, "paramUpdate", CallerID, update.key, update.value)
+			return err
+		})
+	}
+}
+
+func (m *Master) registerService(callerID string, service string, serviceAPI string, callerAPI string) (interface{}, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	service = resolveName(service, callerID)
+	m.registerNode(callerID, callerAPI)
+	m.services[service] = serviceRegistration{callerID, serviceAPI}
+	return buildRosAPIResult(apiStatusSuccess, fmt.Sprintf("Registered [%s] as provider of [%s]", callerID, service), 1), nil
+}
+
+func (m *Master) unregisterService(callerID string, service string, serviceAPI string) (interface{}, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	service = resolveName(service, callerID)
+	reg, ok := m.services[service]
+	if !ok || reg.callerID != callerID || reg.serviceAPI != serviceAPI {
+		return buildRosAPIResult(apiStatusSuccess, fmt.Sprintf("[%s] is not a provider of [%s]", callerID, service), 0), nil
+	}
+	delete(m.services, service)
+	m.cleanupNode(callerID)
+	return buildRosAPIResult(apiStatusSuccess, fmt.Sprintf("Unregistered [%s] as provider of [%s]", callerID, service), 1), nil
+}
+
+func (m *Master) registerSubscriber(callerID string, topic string, topicType string, callerAPI string) (interface{}, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	topic = resolveName(topic, callerID)
+	m.registerNode(callerID, callerAPI)
+	nodes, ok := m.subscribers[topic]
+	if !ok {
+		nodes = make(map[string]string)
+		m.subscribers[topic] = nodes
+	}
+	nodes[callerID] = callerAPI
+	if _, ok := m.topicTypes[topic]; !ok && topicType != "*" {
+		m.topicTypes[topic] = topicType
+	}
+	publishers := toInterfaces(sortedValues(m.publishers[topic]))
+	return buildRosAPIResult(apiStatusSuccess, fmt.Sprintf("Subscribed to [%s]", topic), publishers), nil
+}
+
+func (m *Master) unregisterSubscriber(callerID string, topic string, callerAPI string) (interface{}, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	topic = resolveName(topic, callerID)
+	nodes, ok := m.subscribers[topic]
+	if !ok || nodes[callerID] != callerAPI {
+		return buildRosAPIResult(apiStatusSuccess, fmt.Sprintf("[%s] is not a subscriber of [%s]", callerID, topic), 0), nil
+	}
+	delete(nodes, callerID)
+	if len(nodes) == 0 {
+		delete(m.subscribers, topic)
+	}
+	m.cleanupNode(callerID)
+	return buildRosAPIResult(apiStatusSuccess, fmt.Sprintf("Unregistered [%s] as subscriber of [%s]", callerID, topic), 1), nil
+}
+
+func (m *Master) registerPublisher(callerID string, topic string, topicType string, callerAPI string) (interface{}, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	topic = resolveName(topic, callerID)
+	m.registerNode(callerID, callerAPI)
+	nodes, ok := m.publishers[topic]
+	if !ok {
+		nodes = make(map[string]string)
+		m.publishers[topic] = nodes
+	}
+	nodes[callerID] = callerAPI
+	if _, ok := m.topicTypes[topic]; !ok || topicType != "*" {
+		m.topicTypes[topic] = topicType
+	}
+	m.notifyPublisherUpdate(topic)
+	subscribers := toInterfaces(sortedValues(m.subscribers[topic]))
+	return buildRosAPIResult(apiStatusSuccess, fmt.Sprintf("Registered [%s] as publisher of [%s]", callerID, topic), subscribers), nil
+}
+
+func (m *Master) unregisterPublisher(callerID string, topic string, callerAPI string) (interface{}, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	topic = resolveName(topic, callerID)
+	nodes, ok := m.publishers[topic]
+	if !ok || nodes[callerID] != callerAPI {
+		return buildRosAPIResult(apiStatusSuccess, fmt.Sprintf("[%s] is not a publisher of [%s]", callerID, topic), 0), nil
+	}
+	delete(nodes, callerID)
+	if len(nodes) == 0 {
+		delete(m.publishers, topic)
+	}
+	m.notifyPublisherUpdate(topic)
+	m.cleanupNode(callerID)
+	return buildRosAPIResult(apiStatusSuccess, fmt.Sprintf("Unregistered [%s] as publisher of [%s]", callerID, topic), 1), nil
+}
+
+func (m *Master) lookupNode(callerID string, nodeName string) (interface{}, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	nodeName = resolveName(nodeName, callerID)
+	if api, ok := m.nodes[nodeName]; ok {
+		return buildRosAPIResult(apiStatusSuccess, fmt.Sprintf("node api for [%s]", nodeName), api), nil
+	}
+	return buildRosAPIResult(apiStatusError, fmt.Sprintf("unknown node [%s]", nodeName), ""), nil
+}
+
+func (m *Master) getPublishedTopics(callerID string, subgraph string) (interface{}, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	prefix := ""
+	if len(subgraph) > 0 {
+		prefix = resolveName(subgraph, callerID)
+		if !strings.HasSuffix(prefix, sep) {
+			prefix += sep
+		}
+	}
+	topics := []string{}
+	for topic := range m.publishers {
+		if strings.HasPrefix(topic, prefix) {
+			topics = append(topics, topic)
+		}
+	}
+	sort.Strings(topics)
+	result := []interface{}{}
+	for _, topic := range topics {
+		result = append(result, []interface{}{topic, m.topicTypes[topic]})
+	}
+	return buildRosAPIResult(apiStatusSuccess, "current topics", result), nil
+}
+
+func (m *Master) getTopicTypes(callerID string) (interface{}, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	result := []interface{}{}
+	for _, topic := range sortedKeys(m.topicTypes) {
+		result = append(result, []interface{}{topic, m.topicTypes[topic]})
+	}
+	return buildRosAPIResult(apiStatusSuccess, "current system state", result), nil
+}
+
+func systemStateOf(registrations map[string]map[string]string) []interface{} {
+	names := []string{}
+	for name := range registrations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := []interface{}{}
+	for _, name := range names {
+		result = append(result, []interface{}{name, toInterfaces(sortedKeys(registrations[name]))})
+	}
+	return result
+}
+
+func (m *Master) getSystemState(callerID string) (interface{}, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	services := make(map[string]map[string]string)
+	for service, reg := range m.services {
+		services[service] = map[string]string{reg.callerID: reg.serviceAPI}
+	}
+	state := []interface{}{
+		systemStateOf(m.publishers),
+		systemStateOf(m.subscribers),
+		systemStateOf(services),
+	}
+	return buildRosAPIResult(apiStatusSuccess, "current system state", state), nil
+}
+
+func (m *Master) getURI(callerID string) (interface{}, error) {
+	return buildRosAPIResult(apiStatusSuccess, "", m.uri), nil
+}
+
+func (m *Master) getPid(callerID string) (interface{}, error) {
+	return buildRosAPIResult(apiStatusSuccess, "", os.Getpid()), nil
+}
+
+func (m *Master) lookupService(callerID string, service string) (interface{}, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	service = resolveName(service, callerID)
+	if reg, ok := m.services[service]; ok {
+		return buildRosAPIResult(apiStatusSuccess, fmt.Sprintf("rosrpc URI: [%s]", reg.serviceAPI), reg.serviceAPI), nil
+	}
+	return buildRosAPIResult(apiStatusError, fmt.Sprintf("no provider for [%s]", service), ""), nil
+}
+
+func (m *Master) deleteParam(callerID string, key string) (interface{}, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	key = resolveName(key, callerID)
+	if err := m.params.delete(key); err != nil {
+		return buildRosAPIResult(apiStatusError, err.Error(), 0), nil
+	}
+	m.notifyParamUpdates(m.params.updates(key, map[string]interface{}{}))
+	return buildRosAPIResult(apiStatusSuccess, fmt.Sprintf("parameter %s deleted", key), 0), nil
+}
+
+func (m *Master) setParam(callerID string, key string, value interface{}) (interface{}, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	key = resolveName(key, callerID)
+	if err := m.params.set(key, value); err != nil {
+		return buildRosAPIResult(apiStatusError, err.Error(), 0), nil
+	}
+	m.notifyParamUpdates(m.params.updates(key, value))
+	return buildRosAPIResult(apiStatusSuccess, fmt.Sprintf("parameter %s set", key), 0), nil
+}
+
+func (m *Master) getParam(callerID string, key string) (interface{}, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	key = resolveName(key, callerID)
+	if value, ok := m.params.get(key); ok {
+		return buildRosAPIResult(apiStatusSuccess, fmt.Sprintf("Parameter [%s]", key), value), nil
+	}
+	return buildRosAPIResult(apiStatusError, fmt.Sprintf("Parameter [%s] is not set", key), 0), nil
+}
+
+func (m *Master) searchParam(callerID string, key string) (interface{}, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if found, ok := m.params.search(callerID, key); ok {
+		return buildRosAPIResult(apiStatusSuccess, fmt.Sprintf("Found [%s]", found), found), nil
+	}
+	return buildRosAPIResult(apiStatusError, fmt.Sprintf("Cannot find parameter [%s] in an upwards search", key), ""), nil
+}
+
+func (m *Master) subscribeParam(callerID string, callerAPI string, key string) (interface{}, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	key = resolveName(key, callerID)
+	m.registerNode(callerID, callerAPI)
+	value := m.params.subscribe(key, callerID, callerAPI)
+	return buildRosAPIResult(apiStatusSuccess, fmt.Sprintf("Subscribed to parameter [%s]", key), value), nil
+}
+
+func (m *Master) unsubscribeParam(callerID string, callerAPI string, key string) (interface{}, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	key = resolveName(key, callerID)
+	n := m.params.unsubscribe(key, callerID, callerAPI)
+	m.cleanupNode(callerID)
+	return buildRosAPIResult(apiStatusSuccess, fmt.Sprintf("Unsubscribe to parameter [%s]", key), n), nil
+}
+
+func (m *Master) hasParam(callerID string, key string) (interface{}, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	key = resolveName(key, callerID)
+	return buildRosAPIResult(apiStatusSuccess, key, m.params.has(key)), nil
+}
+
+func (m *Master) getParamNames(callerID string) (interface{}, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return buildRosAPIResult(apiStatusSuccess, "Parameter names", toInterfaces(m.params.names())), nil
+}
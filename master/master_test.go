@@ -0,0 +1,213 @@
+package master
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fetchrobotics/rosgo/xmlrpc"
+)
+
+func call(t *testing.T, m *Master, method string, args ...interface{}) interface{} {
+	result, err := xmlrpc.Call(m.URI(), method, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xs := result.([]interface{})
+	if xs[0].(int32) != apiStatusSuccess {
+		t.Fatalf("%s failed: %v", method, xs[1])
+	}
+	return xs[2]
+}
+
+type fakeNode struct {
+	uri      string
+	listener net.Listener
+	calls    chan []interface{}
+}
+
+func newFakeNode(t *testing.T) *fakeNode {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := &fakeNode{
+		uri:      fmt.Sprintf("http://%s/", listener.Addr().String()),
+		listener: listener,
+		calls:    make(chan []interface{}, 10),
+	}
+	m := map[string]xmlrpc.Method{
+		"publisherUpdate": func(callerID string, topic string, publishers []interface{}) (interface{}, error) {
+			n.calls <- []interface{}{"publisherUpdate", topic, publishers}
+			return buildRosAPIResult(apiStatusSuccess, "", 0), nil
+		},
+		"paramUpdate": func(callerID string, key string, value interface{}) (interface{}, error) {
+			n.calls <- []interface{}{"paramUpdate", key, value}
+			return buildRosAPIResult(apiStatusSuccess, "", 0), nil
+		},
+		"shutdown": func(callerID string, msg string) (interface{}, error) {
+			n.calls <- []interface{}{"shutdown", msg}
+			return buildRosAPIResult(apiStatusSuccess, "", 0), nil
+		},
+	}
+	go http.Serve(listener, xmlrpc.NewHandler(m))
+	return n
+}
+
+func (n *fakeNode) expect(t *testing.T, expected ...interface{}) {
+	select {
+	case c := <-n.calls:
+		if !reflect.DeepEqual(c, expected) {
+			t.Errorf("expected %v, got %v", expected, c)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("timeout waiting for %v", expected)
+	}
+}
+
+func newTestMaster(t *testing.T) *Master {
+	m, err := NewMaster("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestPublisherUpdate(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	sub := newFakeNode(t)
+	defer sub.listener.Close()
+
+	pubs := call(t, m, "registerSubscriber", "/listener", "chatter", "std_msgs/String", sub.uri)
+	if len(pubs.([]interface{})) != 0 {
+		t.Error(pubs)
+	}
+	subs := call(t, m, "registerPublisher", "/talker", "/chatter", "std_msgs/String", "http://talker:1234/")
+	if !reflect.DeepEqual(subs, []interface{}{sub.uri}) {
+		t.Error(subs)
+	}
+	sub.expect(t, "publisherUpdate", "/chatter", []interface{}{"http://talker:1234/"})
+
+	call(t, m, "unregisterPublisher", "/talker", "/chatter", "http://talker:1234/")
+	sub.expect(t, "publisherUpdate", "/chatter", []interface{}(nil))
+}
+
+func TestSystemState(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+
+	call(t, m, "registerPublisher", "/ns/talker", "chatter", "std_msgs/String", "http://talker:1234/")
+	call(t, m, "registerSubscriber", "/listener", "/ns/chatter", "std_msgs/String", "http://listener:1234/")
+	call(t, m, "registerService", "/server", "add_two_ints", "rosrpc://server:4321", "http://server:1234/")
+
+	state := call(t, m, "getSystemState", "/tester")
+	expected := []interface{}{
+		[]interface{}{[]interface{}{"/ns/chatter", []interface{}{"/ns/talker"}}},
+		[]interface{}{[]interface{}{"/ns/chatter", []interface{}{"/listener"}}},
+		[]interface{}{[]interface{}{"/add_two_ints", []interface{}{"/server"}}},
+	}
+	if !reflect.DeepEqual(state, expected) {
+		t.Error(state)
+	}
+
+	if uri := call(t, m, "lookupService", "/tester", "/add_two_ints"); uri != "rosrpc://server:4321" {
+		t.Error(uri)
+	}
+	if uri := call(t, m, "lookupNode", "/tester", "/ns/talker"); uri != "http://talker:1234/" {
+		t.Error(uri)
+	}
+	topics := call(t, m, "getPublishedTopics", "/tester", "/ns")
+	if !reflect.DeepEqual(topics, []interface{}{[]interface{}{"/ns/chatter", "std_msgs/String"}}) {
+		t.Error(topics)
+	}
+	if uri := call(t, m, "getUri", "/tester"); uri != m.URI() {
+		t.Error(uri)
+	}
+
+	call(t, m, "unregisterService", "/server", "/add_two_ints", "rosrpc://server:4321")
+	result, err := xmlrpc.Call(m.URI(), "lookupService", "/tester", "/add_two_ints")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := result.([]interface{})[0].(int32); code != apiStatusError {
+		t.Error(code)
+	}
+	result, err = xmlrpc.Call(m.URI(), "lookupNode", "/tester", "/server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := result.([]interface{})[0].(int32); code != apiStatusError {
+		t.Error(code)
+	}
+}
+
+func TestNodeReplacement(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	old := newFakeNode(t)
+	defer old.listener.Close()
+
+	call(t, m, "registerPublisher", "/talker", "/chatter", "std_msgs/String", old.uri)
+	call(t, m, "registerPublisher", "/talker", "/chatter", "std_msgs/String", "http://talker:1234/")
+	old.expect(t, "shutdown", "new node registered with same name [/talker]")
+	if uri := call(t, m, "lookupNode", "/tester", "/talker"); uri != "http://talker:1234/" {
+		t.Error(uri)
+	}
+}
+
+func TestParamUpdate(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	node := newFakeNode(t)
+	defer node.listener.Close()
+
+	value := call(t, m, "subscribeParam", "/ns/node", node.uri, "~gain")
+	if !reflect.DeepEqual(value, map[string]interface{}{}) {
+		t.Error(value)
+	}
+	call(t, m, "setParam", "/tester", "/ns/node/gain", 1.5)
+	node.expect(t, "paramUpdate", "/ns/node/gain/", 1.5)
+
+	call(t, m, "setParam", "/tester", "/ns", map[string]interface{}{"node": map[string]interface{}{"gain": int32(2)}})
+	node.expect(t, "paramUpdate", "/ns/node/gain/", int32(2))
+
+	if value := call(t, m, "getParam", "/ns/node", "~gain"); value != int32(2) {
+		t.Error(value)
+	}
+
+	call(t, m, "deleteParam", "/tester", "/ns")
+	node.expect(t, "paramUpdate", "/ns/node/gain/", map[string]interface{}{})
+
+	if n := call(t, m, "unsubscribeParam", "/ns/node", node.uri, "/ns/node/gain"); n != int32(1) {
+		t.Error(n)
+	}
+}
+
+func TestParams(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+
+	call(t, m, "setParam", "/ns/node", "rate", int32(10))
+	call(t, m, "setParam", "/ns/node", "~name", "foo")
+	if has := call(t, m, "hasParam", "/tester", "/ns/rate"); has != true {
+		t.Error(has)
+	}
+	if key := call(t, m, "searchParam", "/ns/sub/node", "rate"); key != "/ns/rate" {
+		t.Error(key)
+	}
+	names := call(t, m, "getParamNames", "/tester")
+	if !reflect.DeepEqual(names, []interface{}{"/ns/node/name", "/ns/rate"}) {
+		t.Error(names)
+	}
+	result, err := xmlrpc.Call(m.URI(), "getParam", "/tester", "/missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := result.([]interface{})[0].(int32); code != apiStatusError {
+		t.Error(code)
+	}
+}
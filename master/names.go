@@ -0,0 +1,36 @@
+package master
+
+import (
+	"strings"
+
+	"github.com/fetchrobotics/rosgo/internal/names"
+)
+
+const (
+	sep       = "/"
+	globalNS  = "/"
+	privateNS = "~"
+)
+
+// Namespace of a name, always ends with a separator.
+func namespaceOf(name string) string {
+	name = names.Canonicalize(name)
+	if len(name) == 0 || name == globalNS {
+		return globalNS
+	}
+	return name[:strings.LastIndex(name, sep)+1]
+}
+
+// Resolve a name relative to the namespace of the caller, like the reference master does.
+func resolveName(name string, callerID string) string {
+	if len(name) == 0 {
+		return namespaceOf(callerID)
+	}
+	if name[0:1] == globalNS {
+		return names.Canonicalize(name)
+	}
+	if name[0:1] == privateNS {
+		return names.Canonicalize(callerID + sep + name[1:])
+	}
+	return names.Canonicalize(namespaceOf(callerID) + name)
+}
@@ -0,0 +1,79 @@
+package master
+
+import (
+	"sync"
+)
+
+// notifier delivers callbacks to nodes. Each node API gets its own goroutine, so
+// callbacks to one node are delivered in order and a slow node doesn't delay the others.
+// The goroutine exits when its queue drains, or drops the queue when the node fails to
+// respond, so nodes which come and go don't leave goroutines behind.
+type notifier struct {
+	mutex     sync.Mutex
+	queues    map[string]*notifyQueue
+	waitGroup sync.WaitGroup
+	closed    bool
+}
+
+type notifyQueue struct {
+	jobs []func() error
+}
+
+func newNotifier() *notifier {
+	n := new(notifier)
+	n.queues = make(map[string]*notifyQueue)
+	return n
+}
+
+func (n *notifier) notify(api string, job func() error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.closed {
+		return
+	}
+	q, ok := n.queues[api]
+	if !ok {
+		q = new(notifyQueue)
+		n.queues[api] = q
+		n.waitGroup.Add(1)
+		go n.run(api, q)
+	}
+	q.jobs = append(q.jobs, job)
+}
+
+func (n *notifier) run(api string, q *notifyQueue) {
+	defer n.waitGroup.Done()
+	for {
+		n.mutex.Lock()
+		if len(q.jobs) == 0 || n.closed {
+			delete(n.queues, api)
+			n.mutex.Unlock()
+			return
+		}
+		job := q.jobs[0]
+		q.jobs = q.jobs[1:]
+		n.mutex.Unlock()
+		if err := job(); err != nil {
+			// The node is gone, the callbacks queued since are dropped.
+			n.mutex.Lock()
+			delete(n.queues, api)
+			n.mutex.Unlock()
+			return
+		}
+	}
+}
+
+// pending returns the number of node APIs with callbacks being delivered.
+func (n *notifier) pending() int {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return len(n.queues)
+}
+
+// shutdown drops pending callbacks and waits for the ones in flight.
+func (n *notifier) shutdown() {
+	n.mutex.Lock()
+	n.closed = true
+	n.mutex.Unlock()
+	n.waitGroup.Wait()
+}
@@ -0,0 +1,48 @@
+package master
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func waitDrained(t *testing.T, n *notifier) {
+	deadline := time.Now().Add(5 * time.Second)
+	for n.pending() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the notifier goroutines didn't exit")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestNotifierDrains(t *testing.T) {
+	n := newNotifier()
+	defer n.shutdown()
+
+	delivered := make(chan string, 10)
+	for _, api := range []string{"http://a:1/", "http://b:1/", "http://a:1/"} {
+		uri := api
+		n.notify(uri, func() error {
+			delivered <- uri
+			return nil
+		})
+	}
+	waitDrained(t, n)
+	if len(delivered) != 3 {
+		t.Error(len(delivered))
+	}
+
+	// A node which fails to respond loses its pending callbacks.
+	block := make(chan struct{})
+	n.notify("http://c:1/", func() error {
+		<-block
+		return errors.New("connection refused")
+	})
+	n.notify("http://c:1/", func() error {
+		t.Error("callbacks queued after a failure must be dropped")
+		return nil
+	})
+	close(block)
+	waitDrained(t, n)
+}
@@ -0,0 +1,253 @@
+package master
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fetchrobotics/rosgo/internal/names"
+)
+
+// paramUpdate is a paramUpdate callback which has to be delivered to a subscribed node.
+type paramUpdate struct {
+	callerAPI string
+	key       string
+	value     interface{}
+}
+
+// paramServer stores the parameter tree and the parameter subscriptions.
+// It is not goroutine safe, the master serializes all accesses.
+type paramServer struct {
+	root        map[string]interface{}
+	subscribers map[string]map[string]string // key -> callerID -> callerAPI
+}
+
+func newParamServer() *paramServer {
+	ps := new(paramServer)
+	ps.root = make(map[string]interface{})
+	ps.subscribers = make(map[string]map[string]string)
+	return ps
+}
+
+func splitKey(key string) []string {
+	var components []string
+	for _, c := range strings.Split(key, sep) {
+		if len(c) > 0 {
+			components = append(components, c)
+		}
+	}
+	return components
+}
+
+// Subscription keys are canonical names with a trailing separator.
+func subscriptionKey(key string) string {
+	if key == globalNS {
+		return key
+	}
+	return names.Canonicalize(key) + sep
+}
+
+func (ps *paramServer) get(key string) (interface{}, bool) {
+	var value interface{} = ps.root
+	for _, c := range splitKey(key) {
+		dict, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = dict[c]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func (ps *paramServer) has(key string) bool {
+	_, ok := ps.get(key)
+	return ok
+}
+
+func (ps *paramServer) set(key string, value interface{}) error {
+	components := splitKey(key)
+	if len(components) == 0 {
+		dict, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("cannot set root of parameter tree to non-dictionary")
+		}
+		ps.root = dict
+		return nil
+	}
+	dict := ps.root
+	for _, c := range components[:len(components)-1] {
+		child, ok := dict[c].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			dict[c] = child
+		}
+		dict = child
+	}
+	dict[components[len(components)-1]] = value
+	return nil
+}
+
+func (ps *paramServer) delete(key string) error {
+	components := splitKey(key)
+	if len(components) == 0 {
+		return fmt.Errorf("cannot delete root of parameter tree")
+	}
+	dict := ps.root
+	for _, c := range components[:len(components)-1] {
+		child, ok := dict[c].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("parameter [%s] is not set", key)
+		}
+		dict = child
+	}
+	last := components[len(components)-1]
+	if _, ok := dict[last]; !ok {
+		return fmt.Errorf("parameter [%s] is not set", key)
+	}
+	delete(dict, last)
+	return nil
+}
+
+// names returns the full names of all leaf parameters.
+func (ps *paramServer) names() []string {
+	var names []string
+	var walk func(ns string, dict map[string]interface{})
+	walk = func(ns string, dict map[string]interface{}) {
+		for k, v := range dict {
+			name := ns + k
+			if child, ok := v.(map[string]interface{}); ok {
+				walk(name+sep, child)
+			} else {
+				names = append(names, name)
+			}
+		}
+	}
+	walk(globalNS, ps.root)
+	sort.Strings(names)
+	return names
+}
+
+// search looks for key upwards from the namespace ns, starting from the most specific one.
+func (ps *paramServer) search(ns string, key string) (string, bool) {
+	if len(key) == 0 || key[0:1] == privateNS {
+		return "", false
+	}
+	if key[0:1] == globalNS {
+		return key, ps.has(key)
+	}
+	keyComponents := splitKey(key)
+	if len(keyComponents) == 0 {
+		return "", false
+	}
+	keyNS := keyComponents[0]
+	namespaces := splitKey(ns)
+	for i := len(namespaces); i >= 0; i-- {
+		prefix := globalNS + strings.Join(namespaces[:i], sep)
+		if i > 0 {
+			prefix += sep
+		}
+		if ps.has(prefix + keyNS) {
+			return names.Canonicalize(prefix + key), true
+		}
+	}
+	return "", false
+}
+
+func (ps *paramServer) subscribe(key string, callerID string, callerAPI string) interface{} {
+	subKey := subscriptionKey(key)
+	nodes, ok := ps.subscribers[subKey]
+	if !ok {
+		nodes = make(map[string]string)
+		ps.subscribers[subKey] = nodes
+	}
+	nodes[callerID] = callerAPI
+	if value, ok := ps.get(key); ok {
+		return value
+	}
+	return map[string]interface{}{}
+}
+
+func (ps *paramServer) unsubscribe(key string, callerID string, callerAPI string) int {
+	subKey := subscriptionKey(key)
+	nodes, ok := ps.subscribers[subKey]
+	if !ok || nodes[callerID] != callerAPI {
+		return 0
+	}
+	delete(nodes, callerID)
+	if len(nodes) == 0 {
+		delete(ps.subscribers, subKey)
+	}
+	return 1
+}
+
+// unsubscribeNode drops all subscriptions of the node.
+func (ps *paramServer) unsubscribeNode(callerID string) {
+	for key, nodes := range ps.subscribers {
+		delete(nodes, callerID)
+		if len(nodes) == 0 {
+			delete(ps.subscribers, key)
+		}
+	}
+}
+
+func (ps *paramServer) isSubscriber(callerID string) bool {
+	for _, nodes := range ps.subscribers {
+		if _, ok := nodes[callerID]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Collect the keys of all the values in the tree, dictionaries included.
+func allKeys(key string, value map[string]interface{}, keys map[string]bool) {
+	for k, v := range value {
+		childKey := key + k + sep
+		keys[childKey] = true
+		if child, ok := v.(map[string]interface{}); ok {
+			allKeys(childKey, child, keys)
+		}
+	}
+}
+
+// updates computes the paramUpdate callbacks caused by setting key to value.
+// Deleted parameters are reported with an empty dictionary, as the reference master does.
+func (ps *paramServer) updates(key string, value interface{}) []paramUpdate {
+	if len(ps.subscribers) == 0 {
+		return nil
+	}
+	paramKey := subscriptionKey(key)
+	var keys map[string]bool
+	dict, isDict := value.(map[string]interface{})
+	if isDict {
+		keys = make(map[string]bool)
+		allKeys(paramKey, dict, keys)
+	}
+
+	var updates []paramUpdate
+	notify := func(subKey string, k string, v interface{}) {
+		for _, api := range ps.subscribers[subKey] {
+			updates = append(updates, paramUpdate{api, k, v})
+		}
+	}
+	for subKey := range ps.subscribers {
+		if strings.HasPrefix(paramKey, subKey) {
+			notify(subKey, paramKey, value)
+		} else if isDict && strings.HasPrefix(subKey, paramKey) && !keys[subKey] {
+			notify(subKey, subKey, map[string]interface{}{})
+		}
+	}
+	for subKey := range keys {
+		if _, ok := ps.subscribers[subKey]; !ok {
+			continue
+		}
+		var v interface{} = dict
+		for _, c := range splitKey(subKey[len(paramKey):]) {
+			v = v.(map[string]interface{})[c]
+		}
+		notify(subKey, subKey, v)
+	}
+	return updates
+}
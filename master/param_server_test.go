@@ -0,0 +1,99 @@
+package master
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestParamTree(t *testing.T) {
+	ps := newParamServer()
+	ps.set("/a/b/c", int32(1))
+	ps.set("/a/d", "x")
+	if v, ok := ps.get("/a/b"); !ok || !reflect.DeepEqual(v, map[string]interface{}{"c": int32(1)}) {
+		t.Error(v)
+	}
+	if err := ps.set("/", int32(1)); err == nil {
+		t.Error("root must be a dictionary")
+	}
+	if err := ps.delete("/a/b"); err != nil {
+		t.Error(err)
+	}
+	if ps.has("/a/b/c") {
+		t.Error("/a/b/c was not deleted")
+	}
+	if err := ps.delete("/a/b"); err == nil {
+		t.Error("deleting a missing parameter must fail")
+	}
+	if names := ps.names(); !reflect.DeepEqual(names, []string{"/a/d"}) {
+		t.Error(names)
+	}
+}
+
+func TestParamSearch(t *testing.T) {
+	ps := newParamServer()
+	ps.set("/foo", int32(1))
+	ps.set("/a/foo/bar", int32(2))
+	ps.set("/a/b/node/baz", int32(3))
+
+	cases := []struct {
+		ns       string
+		key      string
+		expected string
+		found    bool
+	}{
+		{"/a/b/node", "foo", "/a/foo", true},
+		{"/a/b/node", "foo/bar", "/a/foo/bar", true},
+		{"/node", "foo", "/foo", true},
+		{"/a/b/node", "baz", "/a/b/node/baz", true},
+		{"/node", "baz", "", false},
+		{"/node", "/a/foo", "/a/foo", true},
+		{"/node", "~foo", "", false},
+	}
+	for _, c := range cases {
+		key, found := ps.search(c.ns, c.key)
+		if key != c.expected || found != c.found {
+			t.Errorf("search(%s, %s) = (%s, %v)", c.ns, c.key, key, found)
+		}
+	}
+}
+
+func TestParamUpdates(t *testing.T) {
+	ps := newParamServer()
+	ps.subscribe("/a/b", "/n1", "http://n1/")
+	ps.subscribe("/a", "/n2", "http://n2/")
+	ps.subscribe("/a/c", "/n3", "http://n3/")
+
+	updates := ps.updates("/a/b/x", int32(1))
+	sortUpdates(updates)
+	expected := []paramUpdate{
+		{"http://n1/", "/a/b/x/", int32(1)},
+		{"http://n2/", "/a/b/x/", int32(1)},
+	}
+	if !reflect.DeepEqual(updates, expected) {
+		t.Error(updates)
+	}
+
+	value := map[string]interface{}{"b": int32(2)}
+	updates = ps.updates("/a", value)
+	sortUpdates(updates)
+	expected = []paramUpdate{
+		{"http://n1/", "/a/b/", int32(2)},
+		{"http://n2/", "/a/", value},
+		{"http://n3/", "/a/c/", map[string]interface{}{}},
+	}
+	if !reflect.DeepEqual(updates, expected) {
+		t.Error(updates)
+	}
+
+	ps.unsubscribeNode("/n2")
+	if ps.isSubscriber("/n2") {
+		t.Error("/n2 is still subscribed")
+	}
+}
+
+func sortUpdates(updates []paramUpdate) {
+	sort.Slice(updates, func(i, j int) bool {
+		return updates[i].callerAPI < updates[j].callerAPI
+	})
+}
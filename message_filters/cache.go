@@ -0,0 +1,125 @@
+package message_filters
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/fetchrobotics/rosgo/ros"
+)
+
+type stampedMessage struct {
+	stamp uint64
+	msg   ros.Message
+}
+
+// Cache keeps the last messages of its input sorted by stamp, and passes them on.
+type Cache struct {
+	signal
+	mutex    sync.Mutex
+	size     int
+	messages []stampedMessage // Sorted by stamp, oldest first.
+}
+
+// NewCache creates a cache of the size last messages of input. Messages without
+// Header.Stamp are dropped.
+func NewCache(input Filter, size int) (*Cache, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("invalid cache size %d", size)
+	}
+	c := &Cache{size: size}
+	if input != nil {
+		input.RegisterCallback(func(msg ros.Message) {
+			if err := c.Add(msg); err != nil {
+				logger.Warnf("Cache dropped a message: %v", err)
+			}
+		})
+	}
+	return c, nil
+}
+
+// Add inserts a message, the oldest message is removed if the cache is full. A message older
+// than all the messages of a full cache is dropped, and isn't passed on.
+func (c *Cache) Add(msg ros.Message) error {
+	stamp, err := stampNSec(msg)
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	i := sort.Search(len(c.messages), func(i int) bool { return c.messages[i].stamp > stamp })
+	if i == 0 && len(c.messages) == c.size {
+		c.mutex.Unlock()
+		return nil
+	}
+	c.messages = append(c.messages, stampedMessage{})
+	copy(c.messages[i+1:], c.messages[i:])
+	c.messages[i] = stampedMessage{stamp, msg}
+	if len(c.messages) > c.size {
+		c.messages = c.messages[len(c.messages)-c.size:]
+	}
+	c.mutex.Unlock()
+	c.signalMessage(msg)
+	return nil
+}
+
+// GetInterval returns the messages stamped between start and end included, oldest first.
+func (c *Cache) GetInterval(start, end ros.Time) []ros.Message {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	from := c.search(start.ToNSec())
+	to := c.search(end.ToNSec() + 1)
+	result := make([]ros.Message, 0, to-from)
+	for _, m := range c.messages[from:to] {
+		result = append(result, m.msg)
+	}
+	return result
+}
+
+// GetElemBeforeTime returns the latest message stamped at or before t, or nil.
+func (c *Cache) GetElemBeforeTime(t ros.Time) ros.Message {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	i := c.search(t.ToNSec() + 1)
+	if i == 0 {
+		return nil
+	}
+	return c.messages[i-1].msg
+}
+
+// GetElemAfterTime returns the oldest message stamped at or after t, or nil.
+func (c *Cache) GetElemAfterTime(t ros.Time) ros.Message {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	i := c.search(t.ToNSec())
+	if i == len(c.messages) {
+		return nil
+	}
+	return c.messages[i].msg
+}
+
+// GetOldestTime returns the stamp of the oldest message, zero if the cache is empty.
+func (c *Cache) GetOldestTime() ros.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var t ros.Time
+	if len(c.messages) > 0 {
+		t.FromNSec(c.messages[0].stamp)
+	}
+	return t
+}
+
+// GetLatestTime returns the stamp of the latest message, zero if the cache is empty.
+func (c *Cache) GetLatestTime() ros.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var t ros.Time
+	if len(c.messages) > 0 {
+		t.FromNSec(c.messages[len(c.messages)-1].stamp)
+	}
+	return t
+}
+
+// search returns the index of the first message stamped at or after stamp.
+func (c *Cache) search(stamp uint64) int {
+	return sort.Search(len(c.messages), func(i int) bool { return c.messages[i].stamp >= stamp })
+}
@@ -0,0 +1,66 @@
+package message_filters
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fetchrobotics/rosgo/ros"
+)
+
+func nsecTime(nsec uint64) ros.Time {
+	var t ros.Time
+	t.FromNSec(nsec)
+	return t
+}
+
+func TestCache(t *testing.T) {
+	if _, err := NewCache(nil, 0); err == nil {
+		t.Error("the size must be positive")
+	}
+	var input signal
+	cache, err := NewCache(&input, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var passed []ros.Message
+	cache.RegisterCallback(func(msg ros.Message) { passed = append(passed, msg) })
+
+	if cache.GetOldestTime() != (ros.Time{}) || cache.GetElemBeforeTime(nsecTime(10)) != nil {
+		t.Error("the cache must start empty")
+	}
+	// The message stamped 5 is older than the full cache, it's neither kept nor passed on.
+	for i, stamp := range []uint64{20, 10, 40, 30, 5} {
+		input.signalMessage(newTestMessage(stamp, int32(i)))
+	}
+	if values := values(passed); !reflect.DeepEqual(values, []int32{0, 1, 2, 3}) {
+		t.Error(values)
+	}
+	// The message stamped 10 is the oldest, it's removed.
+	if values := values(cache.GetInterval(nsecTime(0), nsecTime(100))); !reflect.DeepEqual(values, []int32{0, 3, 2}) {
+		t.Error(values)
+	}
+	if values := values(cache.GetInterval(nsecTime(25), nsecTime(30))); !reflect.DeepEqual(values, []int32{3}) {
+		t.Error(values)
+	}
+	if cache.GetOldestTime() != nsecTime(20) || cache.GetLatestTime() != nsecTime(40) {
+		t.Error(cache.GetOldestTime(), cache.GetLatestTime())
+	}
+	if msg := cache.GetElemBeforeTime(nsecTime(35)); msg.(*testMessage).Value != 3 {
+		t.Error(msg)
+	}
+	if msg := cache.GetElemBeforeTime(nsecTime(30)); msg.(*testMessage).Value != 3 {
+		t.Error(msg)
+	}
+	if msg := cache.GetElemAfterTime(nsecTime(21)); msg.(*testMessage).Value != 3 {
+		t.Error(msg)
+	}
+	if cache.GetElemBeforeTime(nsecTime(19)) != nil || cache.GetElemAfterTime(nsecTime(41)) != nil {
+		t.Error("no message expected out of the cached stamps")
+	}
+	if err := cache.Add(&struct {
+		*testMessage
+		Header int
+	}{testMessage: newTestMessage(0, 0)}); err == nil {
+		t.Error("messages without Header must fail")
+	}
+}
@@ -0,0 +1,97 @@
+// Package message_filters combines the messages of subscribers like the message_filters
+// package of roscpp and rospy: filters are chained from a Subscriber, through a Cache or a
+// TimeSequencer, to the synchronizers which pair the messages of several topics by stamp.
+package message_filters
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/fetchrobotics/rosgo/ros"
+)
+
+// Filter is a stage of a chain of filters, which passes its output messages to the callbacks
+// registered by the next stages.
+type Filter interface {
+	RegisterCallback(callback func(msg ros.Message))
+}
+
+// logger reports the messages which the filters without a node drop.
+var logger ros.Logger = ros.NewDefaultLogger()
+
+// signal keeps the callbacks registered to a filter.
+type signal struct {
+	mutex     sync.Mutex
+	callbacks []func(msg ros.Message)
+}
+
+func (s *signal) RegisterCallback(callback func(msg ros.Message)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.callbacks = append(s.callbacks, callback)
+}
+
+func (s *signal) signalMessage(msg ros.Message) {
+	s.mutex.Lock()
+	callbacks := make([]func(msg ros.Message), len(s.callbacks))
+	copy(callbacks, s.callbacks)
+	s.mutex.Unlock()
+	for _, callback := range callbacks {
+		callback(msg)
+	}
+}
+
+var timeType = reflect.TypeOf(ros.Time{})
+
+// Stamp returns the Header.Stamp of a message, messages without header are errors.
+func Stamp(msg ros.Message) (ros.Time, error) {
+	v := reflect.ValueOf(msg)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct {
+		if header := v.FieldByName("Header"); header.Kind() == reflect.Struct {
+			if stamp := header.FieldByName("Stamp"); stamp.IsValid() && stamp.Type() == timeType {
+				return stamp.Interface().(ros.Time), nil
+			}
+		}
+	}
+	return ros.Time{}, fmt.Errorf("%T has no Header.Stamp", msg)
+}
+
+func stampNSec(msg ros.Message) (uint64, error) {
+	stamp, err := Stamp(msg)
+	if err != nil {
+		return 0, err
+	}
+	return stamp.ToNSec(), nil
+}
+
+// Subscriber is the source of a chain of filters, it passes on the messages of a topic.
+type Subscriber struct {
+	signal
+	sub ros.Subscriber
+}
+
+// NewSubscriber subscribes to topic, the messages are passed to the callbacks registered to
+// the returned filter.
+func NewSubscriber(node ros.Node, topic string, msgType ros.MessageType, options ...ros.SubscriberOption) (*Subscriber, error) {
+	s := new(Subscriber)
+	sub, err := node.NewSubscriberE(topic, msgType, func(msg ros.Message) { s.signalMessage(msg) }, options...)
+	if err != nil {
+		return nil, err
+	}
+	s.sub = sub
+	return s, nil
+}
+
+// Add passes a message as if it was received on the topic.
+func (s *Subscriber) Add(msg ros.Message) {
+	s.signalMessage(msg)
+}
+
+// Shutdown unsubscribes from the topic.
+func (s *Subscriber) Shutdown() {
+	s.sub.Shutdown()
+}
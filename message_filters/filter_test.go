@@ -0,0 +1,141 @@
+package message_filters
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fetchrobotics/rosgo/master"
+	"github.com/fetchrobotics/rosgo/ros"
+)
+
+type testHeader struct {
+	Stamp ros.Time
+}
+
+type testMessageType struct{}
+
+func (t *testMessageType) Text() string {
+	return "Header header\nint32 value\n"
+}
+
+func (t *testMessageType) MD5Sum() string {
+	return "5f0ce9c2f4a5a5b5c9d1d3e4f5a6b7c8"
+}
+
+func (t *testMessageType) Name() string {
+	return "message_filters/Test"
+}
+
+func (t *testMessageType) NewMessage() ros.Message {
+	return new(testMessage)
+}
+
+var msgTest = &testMessageType{}
+
+type testMessage struct {
+	Header testHeader
+	Value  int32
+}
+
+func newTestMessage(nsec uint64, value int32) *testMessage {
+	msg := &testMessage{Value: value}
+	msg.Header.Stamp.FromNSec(nsec)
+	return msg
+}
+
+func (m *testMessage) GetType() ros.MessageType {
+	return msgTest
+}
+
+func (m *testMessage) Serialize(buf *bytes.Buffer) error {
+	return binary.Write(buf, binary.LittleEndian, []uint32{m.Header.Stamp.Sec, m.Header.Stamp.NSec, uint32(m.Value)})
+}
+
+func (m *testMessage) Deserialize(buf *ros.Reader) error {
+	var fields [3]uint32
+	if err := binary.Read(buf, binary.LittleEndian, &fields); err != nil {
+		return err
+	}
+	m.Header.Stamp = ros.NewTime(fields[0], fields[1])
+	m.Value = int32(fields[2])
+	return nil
+}
+
+func values(msgs []ros.Message) []int32 {
+	result := make([]int32, len(msgs))
+	for i, msg := range msgs {
+		result[i] = msg.(*testMessage).Value
+	}
+	return result
+}
+
+func newTestNode(t *testing.T, name string) (*master.Master, ros.Node) {
+	m, err := master.NewMaster("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logDir := filepath.Join(os.TempDir(), "rosgo_test_log")
+	node, err := ros.NewNode(name, []string{"__master:=" + m.URI(), "__ip:=127.0.0.1", "__log:=" + logDir})
+	if err != nil {
+		m.Shutdown()
+		t.Fatal(err)
+	}
+	return m, node
+}
+
+// spinUntil spins the node until the condition holds or the timeout expires.
+func spinUntil(node ros.Node, timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		node.SpinOnce()
+	}
+	return cond()
+}
+
+func TestStamp(t *testing.T) {
+	stamp, err := Stamp(newTestMessage(1500000000, 0))
+	if err != nil || stamp != ros.NewTime(1, 500000000) {
+		t.Error(stamp, err)
+	}
+	if _, err := Stamp(&struct {
+		*testMessage
+		Header int
+	}{testMessage: newTestMessage(0, 0)}); err == nil {
+		t.Error("messages without Header must fail")
+	}
+}
+
+func TestSubscriber(t *testing.T) {
+	m, node := newTestNode(t, "/message_filters_test")
+	defer m.Shutdown()
+	defer node.Shutdown()
+
+	sub, err := NewSubscriber(node, "/filtered", msgTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Shutdown()
+	var received []ros.Message
+	sub.RegisterCallback(func(msg ros.Message) { received = append(received, msg) })
+
+	pub := node.NewPublisher("/filtered", msgTest)
+	defer pub.Shutdown()
+	if !spinUntil(node, 5*time.Second, func() bool {
+		if len(received) == 0 {
+			pub.Publish(newTestMessage(1000, 7))
+		}
+		return len(received) > 0
+	}) {
+		t.Fatal("no message received")
+	}
+	if msg := received[0].(*testMessage); msg.Value != 7 || msg.Header.Stamp.ToNSec() != 1000 {
+		t.Error(msg)
+	}
+}
@@ -0,0 +1,209 @@
+package message_filters
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/fetchrobotics/rosgo/ros"
+)
+
+// Synchronizer pairs the messages of several inputs by stamp, and calls its callbacks with one
+// message of every input, in the order of the inputs. Once a set is passed on, the older
+// messages are dropped, so the stamps of the sets always increase.
+type Synchronizer struct {
+	mutex     sync.Mutex
+	callbacks []func(msgs []ros.Message)
+	queueSize int
+	queues    []map[uint64]ros.Message // Messages of every input by stamp.
+	last      []uint64                 // Stamps of the last set passed on, nil before the first one.
+	// match returns the stamps of a set of messages containing the message of input index
+	// stamped stamp, nil if there is none.
+	match func(index int, stamp uint64) []uint64
+}
+
+// NewExactTimeSynchronizer creates a synchronizer pairing the messages with the same stamp.
+// Every input keeps its queueSize latest messages. Nil inputs are fed with Add.
+func NewExactTimeSynchronizer(queueSize int, inputs ...Filter) (*Synchronizer, error) {
+	s, err := newSynchronizer(queueSize, inputs)
+	if err != nil {
+		return nil, err
+	}
+	s.match = s.matchExact
+	return s, nil
+}
+
+// NewApproximateTimeSynchronizer creates a synchronizer pairing messages whose stamps are at
+// most slop apart. Every input keeps its queueSize latest messages. Nil inputs are fed with
+// Add.
+//
+// The pairing follows the slop semantics of rospy's ApproximateTimeSynchronizer, not the
+// adaptive algorithm of roscpp: a set is passed on as soon as the arriving message completes
+// one, with the messages of the other inputs closest to it.
+func NewApproximateTimeSynchronizer(queueSize int, slop ros.Duration, inputs ...Filter) (*Synchronizer, error) {
+	s, err := newSynchronizer(queueSize, inputs)
+	if err != nil {
+		return nil, err
+	}
+	maxDelta := slop.ToNSec()
+	s.match = func(index int, stamp uint64) []uint64 {
+		return s.matchApproximate(index, stamp, maxDelta)
+	}
+	return s, nil
+}
+
+func newSynchronizer(queueSize int, inputs []Filter) (*Synchronizer, error) {
+	if queueSize < 1 {
+		return nil, fmt.Errorf("invalid queue size %d", queueSize)
+	}
+	s := &Synchronizer{queueSize: queueSize}
+	s.queues = make([]map[uint64]ros.Message, len(inputs))
+	for i, input := range inputs {
+		s.queues[i] = make(map[uint64]ros.Message)
+		if input != nil {
+			index := i
+			input.RegisterCallback(func(msg ros.Message) {
+				if err := s.Add(index, msg); err != nil {
+					logger.Warnf("Synchronizer dropped a message: %v", err)
+				}
+			})
+		}
+	}
+	return s, nil
+}
+
+// RegisterCallback adds a callback called with every set of paired messages.
+func (s *Synchronizer) RegisterCallback(callback func(msgs []ros.Message)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.callbacks = append(s.callbacks, callback)
+}
+
+// Add adds a message of the input index. Messages as old as the last set passed on are
+// dropped.
+func (s *Synchronizer) Add(index int, msg ros.Message) error {
+	if index < 0 || index >= len(s.queues) {
+		return fmt.Errorf("no input %d, the synchronizer has %d inputs", index, len(s.queues))
+	}
+	stamp, err := stampNSec(msg)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	if s.last != nil && stamp <= s.last[index] {
+		s.mutex.Unlock()
+		return nil
+	}
+	queue := s.queues[index]
+	queue[stamp] = msg
+	for len(queue) > s.queueSize {
+		delete(queue, oldestStamp(queue))
+	}
+	var msgs []ros.Message
+	if _, ok := queue[stamp]; ok {
+		if stamps := s.match(index, stamp); stamps != nil {
+			msgs = make([]ros.Message, len(s.queues))
+			for i, t := range stamps {
+				msgs[i] = s.queues[i][t]
+				for older := range s.queues[i] {
+					if older <= t {
+						delete(s.queues[i], older)
+					}
+				}
+			}
+			s.last = stamps
+		}
+	}
+	callbacks := make([]func(msgs []ros.Message), len(s.callbacks))
+	copy(callbacks, s.callbacks)
+	s.mutex.Unlock()
+	if msgs != nil {
+		for _, callback := range callbacks {
+			callback(msgs)
+		}
+	}
+	return nil
+}
+
+func (s *Synchronizer) matchExact(index int, stamp uint64) []uint64 {
+	stamps := make([]uint64, len(s.queues))
+	for i, queue := range s.queues {
+		if _, ok := queue[stamp]; !ok {
+			return nil
+		}
+		stamps[i] = stamp
+	}
+	return stamps
+}
+
+// matchApproximate searches a set of messages at most maxDelta apart around the new message.
+// The candidates of every other input are the messages within maxDelta of the new one, the
+// closest are tried first.
+func (s *Synchronizer) matchApproximate(index int, stamp uint64, maxDelta uint64) []uint64 {
+	candidates := make([][]uint64, len(s.queues))
+	for i, queue := range s.queues {
+		if i == index {
+			candidates[i] = []uint64{stamp}
+			continue
+		}
+		for t := range queue {
+			if stampDelta(t, stamp) <= maxDelta {
+				candidates[i] = append(candidates[i], t)
+			}
+		}
+		if len(candidates[i]) == 0 {
+			return nil
+		}
+		sort.Slice(candidates[i], func(a, b int) bool {
+			return stampDelta(candidates[i][a], stamp) < stampDelta(candidates[i][b], stamp)
+		})
+	}
+	stamps := make([]uint64, len(s.queues))
+	if searchStamps(candidates, stamps, 0, stamp, stamp, maxDelta) {
+		return stamps
+	}
+	return nil
+}
+
+// searchStamps picks the stamps of the inputs from i on, so that all the stamps are within
+// maxDelta of each other. min and max are the bounds of the stamps already picked.
+func searchStamps(candidates [][]uint64, stamps []uint64, i int, min, max, maxDelta uint64) bool {
+	if i == len(candidates) {
+		return true
+	}
+	for _, t := range candidates[i] {
+		lo, hi := min, max
+		if t < lo {
+			lo = t
+		}
+		if t > hi {
+			hi = t
+		}
+		if hi-lo > maxDelta {
+			continue
+		}
+		stamps[i] = t
+		if searchStamps(candidates, stamps, i+1, lo, hi, maxDelta) {
+			return true
+		}
+	}
+	return false
+}
+
+func stampDelta(a, b uint64) uint64 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func oldestStamp(queue map[uint64]ros.Message) uint64 {
+	first := true
+	var oldest uint64
+	for t := range queue {
+		if first || t < oldest {
+			oldest, first = t, false
+		}
+	}
+	return oldest
+}
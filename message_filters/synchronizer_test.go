@@ -0,0 +1,71 @@
+package message_filters
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fetchrobotics/rosgo/ros"
+)
+
+func TestExactTimeSynchronizer(t *testing.T) {
+	if _, err := NewExactTimeSynchronizer(0, nil, nil); err == nil {
+		t.Error("the queue size must be positive")
+	}
+	var image, info signal
+	sync, err := NewExactTimeSynchronizer(2, &image, &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var matched [][]int32
+	sync.RegisterCallback(func(msgs []ros.Message) { matched = append(matched, values(msgs)) })
+
+	image.signalMessage(newTestMessage(5, 1))
+	image.signalMessage(newTestMessage(10, 2))
+	// The message stamped 5 is dropped from the full queue.
+	image.signalMessage(newTestMessage(20, 3))
+	info.signalMessage(newTestMessage(5, 4))
+	info.signalMessage(newTestMessage(15, 5))
+	info.signalMessage(newTestMessage(20, 6))
+	// The late partner of the message stamped 10 is dropped, it's older than the last set.
+	info.signalMessage(newTestMessage(10, 7))
+	image.signalMessage(newTestMessage(30, 8))
+	info.signalMessage(newTestMessage(30, 9))
+	if !reflect.DeepEqual(matched, [][]int32{{3, 6}, {8, 9}}) {
+		t.Error(matched)
+	}
+
+	if err := sync.Add(2, newTestMessage(40, 8)); err == nil {
+		t.Error("Add must check the input index")
+	}
+}
+
+func TestApproximateTimeSynchronizer(t *testing.T) {
+	sync, err := NewApproximateTimeSynchronizer(10, ros.NewDuration(0, 5), nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var matched [][]int32
+	sync.RegisterCallback(func(msgs []ros.Message) { matched = append(matched, values(msgs)) })
+
+	add := func(index int, stamp uint64, value int32) {
+		if err := sync.Add(index, newTestMessage(stamp, value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add(0, 100, 1)
+	add(1, 108, 2)
+	add(2, 103, 3)
+	// 100 and 108 are too far apart, 104 pairs with the closest message of the other inputs.
+	add(1, 104, 4)
+	add(0, 200, 5)
+	add(1, 201, 6)
+	add(2, 190, 7)
+	add(2, 198, 8)
+	// Late messages, older than the last set, are dropped instead of making a set.
+	add(0, 150, 9)
+	add(1, 151, 10)
+	add(2, 152, 11)
+	if !reflect.DeepEqual(matched, [][]int32{{1, 4, 3}, {5, 6, 8}}) {
+		t.Error(matched)
+	}
+}
@@ -0,0 +1,82 @@
+package message_filters
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/fetchrobotics/rosgo/ros"
+)
+
+// TimeSequencer passes on the messages of its input in the order of their stamps. A message is
+// held until the ROS time reaches its stamp plus a delay, messages arriving after a later one
+// was passed on are dropped.
+type TimeSequencer struct {
+	signal
+	mutex     sync.Mutex
+	delay     uint64
+	queueSize int
+	queue     []stampedMessage // Sorted by stamp, oldest first.
+	lastStamp uint64
+	timer     ros.Timer
+}
+
+// NewTimeSequencer creates a time sequencer checking the held messages every updateRate. At
+// most queueSize messages are held, the oldest one is dropped when the queue is full.
+func NewTimeSequencer(node ros.Node, input Filter, delay ros.Duration, updateRate ros.Duration, queueSize int) (*TimeSequencer, error) {
+	if queueSize < 1 {
+		return nil, fmt.Errorf("invalid queue size %d", queueSize)
+	}
+	s := &TimeSequencer{delay: delay.ToNSec(), queueSize: queueSize}
+	if input != nil {
+		logger := node.Logger()
+		input.RegisterCallback(func(msg ros.Message) {
+			if err := s.Add(msg); err != nil {
+				logger.Warnf("Time sequencer dropped a message: %v", err)
+			}
+		})
+	}
+	s.timer = node.NewTimer(updateRate, func(ros.TimerEvent) { s.dispatch() }, false)
+	return s, nil
+}
+
+// Add queues a message.
+func (s *TimeSequencer) Add(msg ros.Message) error {
+	stamp, err := stampNSec(msg)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if stamp < s.lastStamp {
+		return nil
+	}
+	i := sort.Search(len(s.queue), func(i int) bool { return s.queue[i].stamp > stamp })
+	s.queue = append(s.queue, stampedMessage{})
+	copy(s.queue[i+1:], s.queue[i:])
+	s.queue[i] = stampedMessage{stamp, msg}
+	if len(s.queue) > s.queueSize {
+		s.queue = s.queue[len(s.queue)-s.queueSize:]
+	}
+	return nil
+}
+
+// Shutdown stops passing on the messages.
+func (s *TimeSequencer) Shutdown() {
+	s.timer.Stop()
+}
+
+func (s *TimeSequencer) dispatch() {
+	now := ros.Now()
+	s.mutex.Lock()
+	var ready []stampedMessage
+	for len(s.queue) > 0 && s.queue[0].stamp+s.delay <= now.ToNSec() {
+		ready = append(ready, s.queue[0])
+		s.lastStamp = s.queue[0].stamp
+		s.queue = s.queue[1:]
+	}
+	s.mutex.Unlock()
+	for _, m := range ready {
+		s.signalMessage(m.msg)
+	}
+}
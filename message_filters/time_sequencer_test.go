@@ -0,0 +1,65 @@
+package message_filters
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fetchrobotics/rosgo/ros"
+)
+
+func TestTimeSequencer(t *testing.T) {
+	m, node := newTestNode(t, "/time_sequencer_test")
+	defer m.Shutdown()
+	defer node.Shutdown()
+
+	if _, err := NewTimeSequencer(node, nil, ros.Duration{}, ros.NewDuration(0, 10000000), -1); err == nil {
+		t.Error("the queue size must be positive")
+	}
+	var input signal
+	sequencer, err := NewTimeSequencer(node, &input, ros.NewDuration(0, 100000000), ros.NewDuration(0, 10000000), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sequencer.Shutdown()
+	var mutex sync.Mutex
+	var passed []ros.Message
+	sequencer.RegisterCallback(func(msg ros.Message) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		passed = append(passed, msg)
+	})
+
+	now := ros.Now()
+	input.signalMessage(newTestMessage(now.ToNSec()+20000000, 1))
+	input.signalMessage(newTestMessage(now.ToNSec(), 2))
+	input.signalMessage(newTestMessage(now.ToNSec()+10000000, 3))
+	mutex.Lock()
+	if len(passed) != 0 {
+		t.Error("messages must be held for the delay")
+	}
+	mutex.Unlock()
+
+	count := func() int {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(passed)
+	}
+	if !spinUntil(node, 5*time.Second, func() bool { return count() == 3 }) {
+		t.Fatal(count())
+	}
+	// Messages older than the last one passed on are dropped.
+	input.signalMessage(newTestMessage(now.ToNSec()+5000000, 4))
+	input.signalMessage(newTestMessage(now.ToNSec()+30000000, 5))
+	if !spinUntil(node, 5*time.Second, func() bool { return count() == 4 }) {
+		t.Fatal(count())
+	}
+	time.Sleep(50 * time.Millisecond)
+	node.SpinOnce()
+	mutex.Lock()
+	defer mutex.Unlock()
+	if values := values(passed); !reflect.DeepEqual(values, []int32{2, 3, 1, 5}) {
+		t.Error(values)
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package msgs
 
 import (
 	"bytes"
@@ -7,9 +7,12 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"regexp"
 	"strings"
 )
 
+var definitionSeparator = regexp.MustCompile(`(?m)^=+[ \t]*$`)
+
 func isRosPackage(dir string) bool {
 	files, err := ioutil.ReadDir(dir)
 	if err != nil {
@@ -70,6 +73,7 @@ type MsgContext struct {
 	msgPathMap    map[string]string
 	srvPathMap    map[string]string
 	actionPathMap map[string]string
+	msgTextMap    map[string]string
 	msgRegistry   map[string]*MsgSpec
 }
 
@@ -93,6 +97,7 @@ func NewMsgContext(rosPkgPaths []string) (*MsgContext, error) {
 	}
 	ctx.actionPathMap = acts
 
+	ctx.msgTextMap = make(map[string]string)
 	ctx.msgRegistry = make(map[string]*MsgSpec)
 	return ctx, nil
 }
@@ -151,6 +156,8 @@ func (ctx *MsgContext) LoadMsgFromFile(filePath string, fullname string) (*MsgSp
 func (ctx *MsgContext) LoadMsg(fullname string) (*MsgSpec, error) {
 	if spec, ok := ctx.msgRegistry[fullname]; ok {
 		return spec, nil
+	} else if text, ok := ctx.msgTextMap[fullname]; ok {
+		return ctx.LoadMsgFromString(text, fullname)
 	} else {
 		if path, ok := ctx.msgPathMap[fullname]; ok {
 			spec, err := ctx.LoadMsgFromFile(path, fullname)
@@ -166,6 +173,30 @@ func (ctx *MsgContext) LoadMsg(fullname string) (*MsgSpec, error) {
 	}
 }
 
+// LoadMsgFromDefinition loads a message from its full definition, with the definitions of the
+// messages it depends on appended after "MSG: <name>" lines, like the message_definition
+// header of a topic connection.
+func (ctx *MsgContext) LoadMsgFromDefinition(text string, fullname string) (*MsgSpec, error) {
+	sections := definitionSeparator.Split(text, -1)
+	for _, section := range sections[1:] {
+		section = strings.TrimLeft(section, "\n")
+		lines := strings.SplitN(section, "\n", 2)
+		if !strings.HasPrefix(lines[0], "MSG:") {
+			return nil, fmt.Errorf("Syntax error: missing 'MSG:' in the definition of `%s`", fullname)
+		}
+		name := strings.TrimSpace(strings.TrimPrefix(lines[0], "MSG:"))
+		if _, ok := ctx.msgRegistry[name]; ok {
+			continue
+		}
+		if len(lines) == 2 {
+			ctx.msgTextMap[name] = lines[1]
+		} else {
+			ctx.msgTextMap[name] = ""
+		}
+	}
+	return ctx.LoadMsgFromString(sections[0], fullname)
+}
+
 func (ctx *MsgContext) LoadSrvFromString(text string, fullname string) (*SrvSpec, error) {
 	packageName, shortName, err := packageResourceName(fullname)
 	if err != nil {
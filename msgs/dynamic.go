@@ -0,0 +1,327 @@
+package msgs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/fetchrobotics/rosgo/ros"
+)
+
+// Go types of the values of the builtin fields of dynamic messages. Fields of a message type
+// are map[string]interface{}, arrays are []interface{} except arrays of bytes which are []byte.
+var builtinZeroValues = map[string]interface{}{
+	"bool":     false,
+	"int8":     int8(0),
+	"uint8":    uint8(0),
+	"int16":    int16(0),
+	"uint16":   uint16(0),
+	"int32":    int32(0),
+	"uint32":   uint32(0),
+	"int64":    int64(0),
+	"uint64":   uint64(0),
+	"float32":  float32(0),
+	"float64":  float64(0),
+	"string":   "",
+	"char":     uint8(0),
+	"byte":     uint8(0),
+	"time":     ros.Time{},
+	"duration": ros.Duration{},
+}
+
+func isByteType(name string) bool {
+	return name == "uint8" || name == "char" || name == "byte"
+}
+
+// DynamicMessageType is a message type loaded at runtime from its definition, for messages
+// without generated code. Its messages are *DynamicMessage.
+type DynamicMessageType struct {
+	spec       *MsgSpec
+	definition string
+	specs      map[string]*MsgSpec
+}
+
+// NewDynamicMessageType loads the message type fullname from its full definition, like the
+// message_definition header of a connection.
+func NewDynamicMessageType(fullname string, definition string) (*DynamicMessageType, error) {
+	ctx, err := NewMsgContext(nil)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := ctx.LoadMsgFromDefinition(definition, fullname)
+	if err != nil {
+		return nil, err
+	}
+	t := &DynamicMessageType{spec, definition, make(map[string]*MsgSpec)}
+	if err := t.resolve(ctx, spec); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// resolve loads the specs of all the message fields of spec, recursively.
+func (t *DynamicMessageType) resolve(ctx *MsgContext, spec *MsgSpec) error {
+	for _, f := range spec.Fields {
+		if f.IsBuiltin {
+			continue
+		}
+		fullname := f.Package + "/" + f.Type
+		if _, ok := t.specs[fullname]; ok {
+			continue
+		}
+		if len(f.Package) == 0 {
+			return fmt.Errorf("Message definition of `%s` is not found", f.Type)
+		}
+		subspec, err := ctx.LoadMsg(fullname)
+		if err != nil {
+			return err
+		}
+		t.specs[fullname] = subspec
+		if err := t.resolve(ctx, subspec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *DynamicMessageType) Text() string {
+	return t.definition
+}
+
+func (t *DynamicMessageType) MD5Sum() string {
+	return t.spec.MD5Sum
+}
+
+func (t *DynamicMessageType) Name() string {
+	return t.spec.FullName
+}
+
+// Spec returns the parsed definition of the message type.
+func (t *DynamicMessageType) Spec() *MsgSpec {
+	return t.spec
+}
+
+// NewMessage returns a *DynamicMessage with all the fields set to zero values.
+func (t *DynamicMessageType) NewMessage() ros.Message {
+	return &DynamicMessage{t, t.zeroMessage(t.spec)}
+}
+
+func (t *DynamicMessageType) fieldSpec(f *Field) *MsgSpec {
+	return t.specs[f.Package+"/"+f.Type]
+}
+
+func (t *DynamicMessageType) zeroMessage(spec *MsgSpec) map[string]interface{} {
+	data := make(map[string]interface{})
+	for i := range spec.Fields {
+		f := &spec.Fields[i]
+		switch {
+		case f.IsArray && isByteType(f.Type):
+			data[f.Name] = make([]byte, maxInt(f.ArrayLen, 0))
+		case f.IsArray:
+			items := make([]interface{}, maxInt(f.ArrayLen, 0))
+			for j := range items {
+				items[j] = t.zeroValue(f)
+			}
+			data[f.Name] = items
+		default:
+			data[f.Name] = t.zeroValue(f)
+		}
+	}
+	return data
+}
+
+func (t *DynamicMessageType) zeroValue(f *Field) interface{} {
+	if f.IsBuiltin {
+		return builtinZeroValues[f.Type]
+	}
+	return t.zeroMessage(t.fieldSpec(f))
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// DynamicMessage is a message of a DynamicMessageType, with its fields stored by name in Data.
+// Missing fields are serialized as zero values.
+type DynamicMessage struct {
+	dynamicType *DynamicMessageType
+	Data        map[string]interface{}
+}
+
+func (m *DynamicMessage) GetType() ros.MessageType {
+	return m.dynamicType
+}
+
+func (m *DynamicMessage) Serialize(buf *bytes.Buffer) error {
+	return m.dynamicType.serializeMessage(buf, m.dynamicType.spec, m.Data)
+}
+
+func (m *DynamicMessage) Deserialize(buf *ros.Reader) error {
+	data, err := m.dynamicType.deserializeMessage(buf, m.dynamicType.spec)
+	if err != nil {
+		return err
+	}
+	m.Data = data
+	return nil
+}
+
+func (t *DynamicMessageType) serializeMessage(buf *bytes.Buffer, spec *MsgSpec, data map[string]interface{}) error {
+	for i := range spec.Fields {
+		f := &spec.Fields[i]
+		if err := t.serializeField(buf, f, data[f.Name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *DynamicMessageType) serializeField(buf *bytes.Buffer, f *Field, value interface{}) error {
+	if !f.IsArray {
+		return t.serializeValue(buf, f, value)
+	}
+	if isByteType(f.Type) {
+		data, ok := value.([]byte)
+		if !ok && value != nil {
+			return fmt.Errorf("field %s: expected []byte, got %T", f.Name, value)
+		}
+		if err := writeArrayLen(buf, f, len(data)); err != nil {
+			return err
+		}
+		buf.Write(data)
+		return nil
+	}
+	items, ok := value.([]interface{})
+	if !ok && value != nil {
+		return fmt.Errorf("field %s: expected []interface{}, got %T", f.Name, value)
+	}
+	if err := writeArrayLen(buf, f, len(items)); err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := t.serializeValue(buf, f, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeArrayLen(buf *bytes.Buffer, f *Field, n int) error {
+	if f.ArrayLen < 0 {
+		return binary.Write(buf, binary.LittleEndian, uint32(n))
+	}
+	if n != f.ArrayLen {
+		return fmt.Errorf("field %s: expected %d items, got %d", f.Name, f.ArrayLen, n)
+	}
+	return nil
+}
+
+func (t *DynamicMessageType) serializeValue(buf *bytes.Buffer, f *Field, value interface{}) error {
+	if !f.IsBuiltin {
+		data, ok := value.(map[string]interface{})
+		if !ok && value != nil {
+			return fmt.Errorf("field %s: expected map[string]interface{}, got %T", f.Name, value)
+		}
+		return t.serializeMessage(buf, t.fieldSpec(f), data)
+	}
+	zero := builtinZeroValues[f.Type]
+	if value == nil {
+		value = zero
+	} else if reflect.TypeOf(value) != reflect.TypeOf(zero) {
+		return fmt.Errorf("field %s: expected %T, got %T", f.Name, zero, value)
+	}
+	switch v := value.(type) {
+	case string:
+		binary.Write(buf, binary.LittleEndian, uint32(len(v)))
+		buf.WriteString(v)
+		return nil
+	case ros.Time:
+		return binary.Write(buf, binary.LittleEndian, []uint32{v.Sec, v.NSec})
+	case ros.Duration:
+		return binary.Write(buf, binary.LittleEndian, []uint32{v.Sec, v.NSec})
+	default:
+		return binary.Write(buf, binary.LittleEndian, v)
+	}
+}
+
+func (t *DynamicMessageType) deserializeMessage(buf *ros.Reader, spec *MsgSpec) (map[string]interface{}, error) {
+	data := make(map[string]interface{})
+	for i := range spec.Fields {
+		f := &spec.Fields[i]
+		value, err := t.deserializeField(buf, f)
+		if err != nil {
+			return nil, err
+		}
+		data[f.Name] = value
+	}
+	return data, nil
+}
+
+func (t *DynamicMessageType) deserializeField(buf *ros.Reader, f *Field) (interface{}, error) {
+	if !f.IsArray {
+		return t.deserializeValue(buf, f)
+	}
+	n := f.ArrayLen
+	if n < 0 {
+		var size uint32
+		if err := binary.Read(buf, binary.LittleEndian, &size); err != nil {
+			return nil, err
+		}
+		n = int(size)
+	}
+	if isByteType(f.Type) {
+		if n > buf.Len() {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return buf.Next(n), nil
+	}
+	// Every item takes at least a byte, except the items of empty messages.
+	if n > buf.Len() && (f.IsBuiltin || len(t.fieldSpec(f).Fields) > 0) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	items := make([]interface{}, n)
+	for i := range items {
+		value, err := t.deserializeValue(buf, f)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = value
+	}
+	return items, nil
+}
+
+func (t *DynamicMessageType) deserializeValue(buf *ros.Reader, f *Field) (interface{}, error) {
+	if !f.IsBuiltin {
+		return t.deserializeMessage(buf, t.fieldSpec(f))
+	}
+	switch f.Type {
+	case "string":
+		var size uint32
+		if err := binary.Read(buf, binary.LittleEndian, &size); err != nil {
+			return nil, err
+		}
+		if int(size) > buf.Len() {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return string(buf.Next(int(size))), nil
+	case "time", "duration":
+		var v [2]uint32
+		if err := binary.Read(buf, binary.LittleEndian, &v); err != nil {
+			return nil, err
+		}
+		if f.Type == "time" {
+			return ros.NewTime(v[0], v[1]), nil
+		}
+		return ros.NewDuration(v[0], v[1]), nil
+	default:
+		ptr := reflect.New(reflect.TypeOf(builtinZeroValues[f.Type]))
+		if err := binary.Read(buf, binary.LittleEndian, ptr.Interface()); err != nil {
+			return nil, err
+		}
+		return ptr.Elem().Interface(), nil
+	}
+}
@@ -0,0 +1,155 @@
+package msgs
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/fetchrobotics/rosgo/ros"
+)
+
+const logDefinition = `##
+## Severity level constants
+##
+byte DEBUG=1 #debug level
+byte INFO=2  #general level
+byte WARN=4  #warning level
+byte ERROR=8 #error level
+byte FATAL=16 #fatal/critical level
+##
+## Fields
+##
+Header header
+byte level
+string name # name of the node
+string msg # message
+string file # file the message came from
+string function # function the message came from
+uint32 line # line the message came from
+string[] topics # topic names that the node publishes
+
+================================================================================
+MSG: std_msgs/Header
+# Standard metadata for higher-level stamped data types.
+uint32 seq
+time stamp
+#Frame this data is associated with
+string frame_id
+`
+
+func TestDynamicMessageTypeMD5(t *testing.T) {
+	var tests = []struct {
+		fullname   string
+		definition string
+		md5sum     string
+	}{
+		{"std_msgs/Header", "uint32 seq\ntime stamp\nstring frame_id\n", "2176decaecbce78abc3b96ef049fabed"},
+		{"rosgraph_msgs/Log", logDefinition, "acffd30cd6b6de30f120938c17c593fb"},
+		{"roscpp/GetLoggersResponse", "Logger[] loggers\n\n" +
+			"================================================================================\n" +
+			"MSG: roscpp/Logger\nstring name\nstring level\n", "32e97e85527d4678a8f9279894bb64b0"},
+		{"std_msgs/Empty", "", "d41d8cd98f00b204e9800998ecf8427e"},
+	}
+	for _, test := range tests {
+		msgType, err := NewDynamicMessageType(test.fullname, test.definition)
+		if err != nil {
+			t.Errorf("%s: %v", test.fullname, err)
+			continue
+		}
+		assertEqual(t, msgType.Name(), test.fullname)
+		assertEqual(t, msgType.MD5Sum(), test.md5sum)
+		assertEqual(t, msgType.Text(), test.definition)
+	}
+}
+
+func TestDynamicMessageTypeMissingDependency(t *testing.T) {
+	if _, err := NewDynamicMessageType("rosgraph_msgs/Log", "Header header\nbyte level\n"); err == nil {
+		t.Error("std_msgs/Header isn't defined")
+	}
+	if _, err := NewDynamicMessageType("rosgraph_msgs/Log", "uint32 line\n====\nstring name\n"); err == nil {
+		t.Error("sections must start with 'MSG:'")
+	}
+}
+
+func TestDynamicMessage(t *testing.T) {
+	msgType, err := NewDynamicMessageType("rosgraph_msgs/Log", logDefinition)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := msgType.NewMessage().(*DynamicMessage)
+	header := msg.Data["header"].(map[string]interface{})
+	if header["seq"] != uint32(0) || header["stamp"] != (ros.Time{}) || msg.Data["name"] != "" {
+		t.Fatal("expected zero values", msg.Data)
+	}
+	header["seq"] = uint32(7)
+	header["stamp"] = ros.NewTime(10, 20)
+	msg.Data["level"] = uint8(2)
+	msg.Data["name"] = "/talker"
+	msg.Data["line"] = uint32(42)
+	msg.Data["topics"] = []interface{}{"/chatter", "/rosout"}
+
+	var buf bytes.Buffer
+	if err := msg.Serialize(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{
+		7, 0, 0, 0, 10, 0, 0, 0, 20, 0, 0, 0, 0, 0, 0, 0, // header
+		2,                                             // level
+		7, 0, 0, 0, '/', 't', 'a', 'l', 'k', 'e', 'r', // name
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // msg, file, function
+		42, 0, 0, 0, // line
+		2, 0, 0, 0, 8, 0, 0, 0, '/', 'c', 'h', 'a', 't', 't', 'e', 'r', 7, 0, 0, 0, '/', 'r', 'o', 's', 'o', 'u', 't',
+	}
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Fatalf("%v != %v", buf.Bytes(), expected)
+	}
+
+	decoded := msgType.NewMessage()
+	if err := decoded.Deserialize(ros.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.(*DynamicMessage).Data, msg.Data) {
+		t.Errorf("%v != %v", decoded.(*DynamicMessage).Data, msg.Data)
+	}
+
+	if err := decoded.Deserialize(ros.NewReader(buf.Bytes()[:20])); err == nil {
+		t.Error("truncated messages must fail")
+	}
+	msg.Data["line"] = 42
+	if err := msg.Serialize(&buf); err == nil {
+		t.Error("fields must have the Go type of their ROS type")
+	}
+}
+
+func TestDynamicMessageArrays(t *testing.T) {
+	msgType, err := NewDynamicMessageType("foo/Arrays", "uint8[] data\nfloat64[2] pair\nfoo/Item[] items\n"+
+		"==========\nMSG: foo/Item\nint16 value\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := msgType.NewMessage().(*DynamicMessage)
+	if len(msg.Data["pair"].([]interface{})) != 2 {
+		t.Fatal("fixed size arrays are filled with zero values", msg.Data)
+	}
+	msg.Data["data"] = []byte{1, 2, 3}
+	msg.Data["items"] = []interface{}{map[string]interface{}{"value": int16(-1)}, nil}
+
+	var buf bytes.Buffer
+	if err := msg.Serialize(&buf); err != nil {
+		t.Fatal(err)
+	}
+	decoded := msgType.NewMessage().(*DynamicMessage)
+	if err := decoded.Deserialize(ros.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	items := decoded.Data["items"].([]interface{})
+	if !bytes.Equal(decoded.Data["data"].([]byte), []byte{1, 2, 3}) || len(items) != 2 ||
+		items[0].(map[string]interface{})["value"] != int16(-1) || items[1].(map[string]interface{})["value"] != int16(0) {
+		t.Error(decoded.Data)
+	}
+
+	msg.Data["pair"] = []interface{}{1.0}
+	if err := msg.Serialize(&buf); err == nil {
+		t.Error("fixed size arrays must have their size")
+	}
+}
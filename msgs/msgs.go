@@ -1,4 +1,7 @@
-package main
+// Package msgs parses the definitions of ROS messages, services and actions and computes
+// their MD5 sums. It is used by gengo, and by DynamicMessageType for messages without
+// generated code.
+package msgs
 
 import (
 	"bytes"
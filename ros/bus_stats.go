@@ -0,0 +1,164 @@
+package ros
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Directions of a connection as reported by getBusInfo.
+const (
+	connectionInbound  = "i"
+	connectionOutbound = "o"
+)
+
+// connectionStats holds the statistics of a topic connection. Counters are updated atomically
+// by the goroutine serving the connection, they come first to be 64-bit aligned.
+type connectionStats struct {
+	bytes       int64 // Bytes sent or received, length prefixes included.
+	messages    int64
+	drops       int64
+	connected   int32
+	id          int
+	destination string // Caller ID of the subscriber or XML-RPC URI of the publisher.
+	direction   string
+	transport   string
+	topic       string
+	bus         *busStats
+}
+
+func (c *connectionStats) addMessage(size int) {
+	atomic.AddInt64(&c.bytes, int64(size))
+	atomic.AddInt64(&c.messages, 1)
+}
+
+func (c *connectionStats) addDrops(n int) {
+	if n > 0 {
+		atomic.AddInt64(&c.drops, int64(n))
+	}
+}
+
+// busStats keeps track of the connections of a node for the getBusStats and getBusInfo slave API.
+type busStats struct {
+	// Service server statistics, summed over all services.
+	numRequests   int64
+	bytesReceived int64
+	bytesSent     int64
+
+	mutex       sync.Mutex
+	connections map[int]*connectionStats
+	lastID      int32
+}
+
+func newBusStats() *busStats {
+	b := new(busStats)
+	b.connections = make(map[int]*connectionStats)
+	return b
+}
+
+// nextConnectionID returns an ID unique among the connections of the node.
+func (b *busStats) nextConnectionID() int {
+	return int(atomic.AddInt32(&b.lastID, 1))
+}
+
+// open registers a new connection.
+func (b *busStats) open(id int, destination, direction, transport, topic string) *connectionStats {
+	c := &connectionStats{
+		id:          id,
+		destination: destination,
+		direction:   direction,
+		transport:   transport,
+		topic:       topic,
+		connected:   1,
+		bus:         b,
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.connections[id] = c
+	return c
+}
+
+// close unregisters the connection.
+func (c *connectionStats) close() {
+	atomic.StoreInt32(&c.connected, 0)
+	c.bus.mutex.Lock()
+	defer c.bus.mutex.Unlock()
+	delete(c.bus.connections, c.id)
+}
+
+func (b *busStats) addServiceCall(received, sent int) {
+	atomic.AddInt64(&b.numRequests, 1)
+	atomic.AddInt64(&b.bytesReceived, int64(received))
+	atomic.AddInt64(&b.bytesSent, int64(sent))
+}
+
+// snapshot returns the open connections ordered by ID.
+func (b *busStats) snapshot() []*connectionStats {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	result := make([]*connectionStats, 0, len(b.connections))
+	for _, c := range b.connections {
+		result = append(result, c)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].id < result[j].id })
+	return result
+}
+
+// stats returns [publishStats, subscribeStats, serviceStats] in the format of getBusStats.
+//
+//	publishStats: [[topic, messageDataSent, [[connectionId, bytesSent, numSent, connected]...]]...]
+//	subscribeStats: [[topic, [[connectionId, bytesReceived, dropEstimate, connected]...]]...]
+//	serviceStats: [numRequests, bytesReceived, bytesSent]
+func (b *busStats) stats() []interface{} {
+	var topics []string
+	pubData := make(map[string][]interface{})
+	pubBytes := make(map[string]int64)
+	subData := make(map[string][]interface{})
+	for _, c := range b.snapshot() {
+		if _, ok := pubData[c.topic]; !ok {
+			if _, ok := subData[c.topic]; !ok {
+				topics = append(topics, c.topic)
+			}
+		}
+		bytes := atomic.LoadInt64(&c.bytes)
+		connected := atomic.LoadInt32(&c.connected) != 0
+		if c.direction == connectionOutbound {
+			pubBytes[c.topic] += bytes
+			pubData[c.topic] = append(pubData[c.topic],
+				[]interface{}{c.id, int(bytes), int(atomic.LoadInt64(&c.messages)), connected})
+		} else {
+			subData[c.topic] = append(subData[c.topic],
+				[]interface{}{c.id, int(bytes), int(atomic.LoadInt64(&c.drops)), connected})
+		}
+	}
+
+	publishStats := []interface{}{}
+	subscribeStats := []interface{}{}
+	for _, topic := range topics {
+		if data, ok := pubData[topic]; ok {
+			publishStats = append(publishStats, []interface{}{topic, int(pubBytes[topic]), data})
+		}
+		if data, ok := subData[topic]; ok {
+			subscribeStats = append(subscribeStats, []interface{}{topic, data})
+		}
+	}
+	serviceStats := []interface{}{
+		int(atomic.LoadInt64(&b.numRequests)),
+		int(atomic.LoadInt64(&b.bytesReceived)),
+		int(atomic.LoadInt64(&b.bytesSent)),
+	}
+	return []interface{}{publishStats, subscribeStats, serviceStats}
+}
+
+// info returns [[connectionId, destinationId, direction, transport, topic, connected]...]
+// in the format of getBusInfo.
+func (b *busStats) info() []interface{} {
+	result := []interface{}{}
+	for _, c := range b.snapshot() {
+		result = append(result, []interface{}{
+			c.id, c.destination, c.direction, c.transport, c.topic,
+			atomic.LoadInt32(&c.connected) != 0,
+		})
+	}
+	return result
+}
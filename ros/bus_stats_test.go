@@ -0,0 +1,71 @@
+package ros
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBusInfoAndStats(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	node := newTestNode(t, m, "/bus_test")
+	defer node.Shutdown()
+
+	pub := node.NewPublisher("/bus", msgTestMessage)
+	received := 0
+	node.NewSubscriber("/bus", msgTestMessage, func(msg *testMessage) {
+		received++
+	})
+	// Let the subscriber connect.
+	time.Sleep(500 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		pub.Publish(&testMessage{"abcd"})
+	}
+	if !spinUntil(node, 5*time.Second, func() bool { return received == 3 }) {
+		t.Fatal("messages were not received")
+	}
+
+	result, err := callRosAPI(node.xmlrpcURI, "getBusInfo", "/tester")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := result.([]interface{})
+	if len(info) != 2 {
+		t.Fatal(info)
+	}
+	directions := map[string]string{}
+	for _, x := range info {
+		conn := x.([]interface{})
+		if conn[3] != TransportTCPROS || conn[4] != "/bus" || conn[5] != true {
+			t.Error(conn)
+		}
+		directions[conn[2].(string)] = conn[1].(string)
+	}
+	expected := map[string]string{"i": node.xmlrpcURI, "o": "/bus_test"}
+	if !reflect.DeepEqual(directions, expected) {
+		t.Error(directions)
+	}
+
+	result, err = callRosAPI(node.xmlrpcURI, "getBusStats", "/tester")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := result.([]interface{})
+	// 3 messages of 4 byte length, 4 byte string length and 4 bytes of data.
+	pubStats := stats[0].([]interface{})[0].([]interface{})
+	if pubStats[0] != "/bus" || pubStats[1] != int32(36) {
+		t.Error(pubStats)
+	}
+	pubConn := pubStats[2].([]interface{})[0].([]interface{})
+	if pubConn[1] != int32(36) || pubConn[2] != int32(3) || pubConn[3] != true {
+		t.Error(pubConn)
+	}
+	subConn := stats[1].([]interface{})[0].([]interface{})[1].([]interface{})[0].([]interface{})
+	if subConn[1] != int32(36) || subConn[2] != int32(0) || subConn[3] != true {
+		t.Error(subConn)
+	}
+	if !reflect.DeepEqual(stats[2], []interface{}{int32(0), int32(0), int32(0)}) {
+		t.Error(stats[2])
+	}
+}
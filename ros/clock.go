@@ -0,0 +1,177 @@
+package ros
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// A hand written rosgraph_msgs/Clock, the ros package can't depend on generated messages.
+type clockMessageType struct{}
+
+func (t *clockMessageType) Text() string {
+	return "time clock\n"
+}
+
+func (t *clockMessageType) MD5Sum() string {
+	return "a9c97c1d230cfc112e270351a944ee47"
+}
+
+func (t *clockMessageType) Name() string {
+	return "rosgraph_msgs/Clock"
+}
+
+func (t *clockMessageType) NewMessage() Message {
+	return new(clockMessage)
+}
+
+var msgClock = &clockMessageType{}
+
+type clockMessage struct {
+	Clock Time `rosmsg:"clock:time"`
+}
+
+func (m *clockMessage) GetType() MessageType {
+	return msgClock
+}
+
+func (m *clockMessage) Serialize(buf *bytes.Buffer) error {
+	binary.Write(buf, binary.LittleEndian, m.Clock.Sec)
+	binary.Write(buf, binary.LittleEndian, m.Clock.NSec)
+	return nil
+}
+
+func (m *clockMessage) Deserialize(buf *Reader) error {
+	if err := binary.Read(buf, binary.LittleEndian, &m.Clock.Sec); err != nil {
+		return err
+	}
+	return binary.Read(buf, binary.LittleEndian, &m.Clock.NSec)
+}
+
+// simTime holds the simulated time published on /clock. Like in other client libraries, it's
+// shared by all the nodes of the process, and enabled while a node using it runs.
+var simTime struct {
+	mutex      sync.Mutex
+	enabled    bool
+	nodes      map[*defaultNode]bool // The nodes which enabled the simulated time.
+	now        Time
+	changeChan chan struct{} // Closed and replaced on every update, to wake up the waiters.
+}
+
+// enableSimTime makes Now return the simulated time, which is zero until the first update.
+func enableSimTime(node *defaultNode) {
+	simTime.mutex.Lock()
+	defer simTime.mutex.Unlock()
+	if !simTime.enabled {
+		simTime.enabled = true
+		simTime.nodes = make(map[*defaultNode]bool)
+		simTime.changeChan = make(chan struct{})
+	}
+	simTime.nodes[node] = true
+}
+
+// disableSimTime goes back to the wall clock once the last node which enabled the simulated
+// time shuts down.
+func disableSimTime(node *defaultNode) {
+	simTime.mutex.Lock()
+	defer simTime.mutex.Unlock()
+	if !simTime.nodes[node] {
+		return
+	}
+	delete(simTime.nodes, node)
+	if len(simTime.nodes) == 0 {
+		simTime.enabled = false
+		simTime.now = Time{}
+		// The waiters go on with the wall clock.
+		close(simTime.changeChan)
+	}
+}
+
+func setSimTime(t Time) {
+	simTime.mutex.Lock()
+	defer simTime.mutex.Unlock()
+	if !simTime.enabled {
+		return
+	}
+	simTime.now = t
+	close(simTime.changeChan)
+	simTime.changeChan = make(chan struct{})
+}
+
+// IsSimTime returns true if the time comes from /clock instead of the wall clock.
+func IsSimTime() bool {
+	simTime.mutex.Lock()
+	defer simTime.mutex.Unlock()
+	return simTime.enabled
+}
+
+// clock tells the time and waits for it, for timers and sleeps.
+type clock interface {
+	now() Time
+	// waitUntil blocks until the time t, returning false if quitChan is closed before.
+	waitUntil(t Time, quitChan <-chan struct{}) bool
+}
+
+type wallClock struct{}
+
+func (wallClock) now() Time {
+	return wallNow()
+}
+
+func (wallClock) waitUntil(t Time, quitChan <-chan struct{}) bool {
+	now := wallNow()
+	if t.Cmp(now) <= 0 {
+		return true
+	}
+	d := t.Diff(now)
+	select {
+	case <-time.After(time.Duration(d.ToNSec())):
+		return true
+	case <-quitChan:
+		return false
+	}
+}
+
+// rosClock follows the simulated time when it's enabled, and the wall clock otherwise.
+type rosClock struct{}
+
+func (rosClock) now() Time {
+	return Now()
+}
+
+func (rosClock) waitUntil(t Time, quitChan <-chan struct{}) bool {
+	for {
+		simTime.mutex.Lock()
+		if !simTime.enabled {
+			simTime.mutex.Unlock()
+			return wallClock{}.waitUntil(t, quitChan)
+		}
+		now := simTime.now
+		changeChan := simTime.changeChan
+		simTime.mutex.Unlock()
+
+		if t.Cmp(now) <= 0 {
+			return true
+		}
+		select {
+		case <-changeChan:
+		case <-quitChan:
+			return false
+		}
+	}
+}
+
+// subscribeClock drives the simulated time from /clock. Clock messages are handled by a
+// spinner of their own, so that sleeps don't depend on the user spinning the node.
+func (node *defaultNode) subscribeClock() {
+	enableSimTime(node)
+	queue := NewCallbackQueue()
+	node.NewAsyncSpinner(1, queue).Start()
+	_, err := node.NewSubscriberE("/clock", msgClock, func(msg *clockMessage) {
+		setSimTime(msg.Clock)
+	}, SubscriberCallbackQueue(queue), SubscriberQueueSize(1), SubscriberQueuePolicy(QueueDropOldest))
+	if err != nil {
+		node.logger.Errorf("Failed to subscribe /clock: %v", err)
+	}
+}
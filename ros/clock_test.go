@@ -0,0 +1,87 @@
+package ros
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSimTime(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	clockNode := newTestNode(t, m, "/clock_server")
+	defer clockNode.Shutdown()
+	clockNode.SetParam("/use_sim_time", true)
+	clockPub := clockNode.NewPublisher("/clock", msgClock)
+
+	node := newTestNode(t, m, "/sim_time_test")
+	running := true
+	defer func() {
+		if running {
+			node.Shutdown()
+		}
+	}()
+	if !IsSimTime() {
+		t.Fatal("sim time is not enabled")
+	}
+	if now := Now(); !now.IsZero() {
+		t.Error("time must be zero before the first clock message", now)
+	}
+
+	setClock := func(sec uint32) {
+		clockPub.Publish(&clockMessage{NewTime(sec, 0)})
+		deadline := time.Now().Add(5 * time.Second)
+		for Now().Sec != sec && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if now := Now(); now.Sec != sec {
+			t.Fatal("clock was not updated", now)
+		}
+	}
+	// Let the subscriber connect.
+	time.Sleep(500 * time.Millisecond)
+	setClock(100)
+
+	var events []TimerEvent
+	node.NewTimer(NewDuration(2, 0), func(ev TimerEvent) {
+		events = append(events, ev)
+	}, false)
+	done := make(chan struct{})
+	go func() {
+		d := NewDuration(5, 0)
+		d.Sleep()
+		close(done)
+	}()
+
+	setClock(103)
+	if !spinUntil(node, 5*time.Second, func() bool { return len(events) == 1 }) {
+		t.Fatal("timer didn't fire")
+	}
+	select {
+	case <-done:
+		t.Error("sleep returned before the simulated time")
+	case <-time.After(50 * time.Millisecond):
+	}
+	setClock(106)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("sleep didn't return")
+	}
+
+	if !spinUntil(node, 5*time.Second, func() bool { return len(events) >= 2 }) {
+		t.Fatal("timer didn't fire")
+	}
+	if events[0].CurrentExpected.Sec != 102 || events[0].CurrentReal.Sec != 103 {
+		t.Error(events[0])
+	}
+	if events[1].CurrentExpected.Sec != 104 || events[1].CurrentReal.Sec != 106 || events[1].LastReal.Sec != 103 {
+		t.Error(events[1])
+	}
+
+	// The wall clock is back once the node shuts down.
+	node.Shutdown()
+	running = false
+	if now := Now(); IsSimTime() || now.Sec < 1000000 {
+		t.Error("sim time must be disabled after Shutdown", now)
+	}
+}
@@ -29,9 +29,15 @@ func (d *Duration) Cmp(other Duration) int {
 	return cmpUint64(d.ToNSec(), other.ToNSec())
 }
 
+// Sleep waits for the duration, in simulated time if it's enabled.
 func (d *Duration) Sleep() error {
 	if !d.IsZero() {
-		time.Sleep(time.Duration(d.ToNSec()) * time.Nanosecond)
+		if IsSimTime() {
+			now := Now()
+			rosClock{}.waitUntil(now.Add(*d), nil)
+		} else {
+			time.Sleep(time.Duration(d.ToNSec()) * time.Nanosecond)
+		}
 	}
 	return nil
 }
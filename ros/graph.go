@@ -0,0 +1,298 @@
+package ros
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SystemState is the registrations of the graph, by topic or service name.
+type SystemState struct {
+	Publishers  map[string][]string // Names of the nodes publishing each topic.
+	Subscribers map[string][]string // Names of the nodes subscribing each topic.
+	Services    map[string][]string // Names of the nodes providing each service.
+}
+
+// Nodes returns the names of all the nodes with a registration, sorted.
+func (s *SystemState) Nodes() []string {
+	set := make(map[string]bool)
+	for _, registrations := range []map[string][]string{s.Publishers, s.Subscribers, s.Services} {
+		for _, nodes := range registrations {
+			for _, node := range nodes {
+				set[node] = true
+			}
+		}
+	}
+	return sortedNames(set)
+}
+
+// Topics returns the names of the topics with a publisher or a subscriber, sorted.
+func (s *SystemState) Topics() []string {
+	set := make(map[string]bool)
+	for topic := range s.Publishers {
+		set[topic] = true
+	}
+	for topic := range s.Subscribers {
+		set[topic] = true
+	}
+	return sortedNames(set)
+}
+
+// TopicInfo is a topic and its message type.
+type TopicInfo struct {
+	Name string
+	Type string
+}
+
+func sortedNames(set map[string]bool) []string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func decodeRegistrations(value interface{}) (map[string][]string, error) {
+	entries, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("malformed system state")
+	}
+	registrations := make(map[string][]string)
+	for _, entry := range entries {
+		pair, ok := entry.([]interface{})
+		if !ok || len(pair) != 2 {
+			return nil, fmt.Errorf("malformed system state")
+		}
+		name, ok := pair[0].(string)
+		nodes, ok2 := pair[1].([]interface{})
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("malformed system state")
+		}
+		names := []string{}
+		for _, node := range nodes {
+			if s, ok := node.(string); ok {
+				names = append(names, s)
+			}
+		}
+		registrations[name] = names
+	}
+	return registrations, nil
+}
+
+func decodeTopicInfos(value interface{}) ([]TopicInfo, error) {
+	entries, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("malformed topic list")
+	}
+	topics := []TopicInfo{}
+	for _, entry := range entries {
+		pair, ok := entry.([]interface{})
+		if !ok || len(pair) != 2 {
+			return nil, fmt.Errorf("malformed topic list")
+		}
+		name, ok := pair[0].(string)
+		typ, ok2 := pair[1].(string)
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("malformed topic list")
+		}
+		topics = append(topics, TopicInfo{name, typ})
+	}
+	return topics, nil
+}
+
+// GetSystemState returns the publishers, subscribers and services registered with the master.
+func (node *defaultNode) GetSystemState() (*SystemState, error) {
+	result, err := callRosAPI(node.masterURI, "getSystemState", node.qualifiedName)
+	if err != nil {
+		return nil, err
+	}
+	lists, ok := result.([]interface{})
+	if !ok || len(lists) != 3 {
+		return nil, fmt.Errorf("malformed system state")
+	}
+	state := new(SystemState)
+	if state.Publishers, err = decodeRegistrations(lists[0]); err != nil {
+		return nil, err
+	}
+	if state.Subscribers, err = decodeRegistrations(lists[1]); err != nil {
+		return nil, err
+	}
+	if state.Services, err = decodeRegistrations(lists[2]); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// GetTopicTypes returns all the topics known by the master, with or without publishers.
+func (node *defaultNode) GetTopicTypes() ([]TopicInfo, error) {
+	result, err := callRosAPI(node.masterURI, "getTopicTypes", node.qualifiedName)
+	if err != nil {
+		return nil, err
+	}
+	return decodeTopicInfos(result)
+}
+
+// GetPublishedTopics returns the topics with publishers in subgraph, or in the whole graph if
+// subgraph is empty.
+func (node *defaultNode) GetPublishedTopics(subgraph string) ([]TopicInfo, error) {
+	if subgraph != "" {
+		subgraph = node.nameResolver.remap(subgraph)
+	}
+	result, err := callRosAPI(node.masterURI, "getPublishedTopics", node.qualifiedName, subgraph)
+	if err != nil {
+		return nil, err
+	}
+	return decodeTopicInfos(result)
+}
+
+// LookupNode returns the XML-RPC URI of a node.
+func (node *defaultNode) LookupNode(name string) (string, error) {
+	result, err := callRosAPI(node.masterURI, "lookupNode", node.qualifiedName, node.nameResolver.remap(name))
+	if err != nil {
+		return "", err
+	}
+	uri, ok := result.(string)
+	if !ok {
+		return "", fmt.Errorf("result of 'lookupNode' is not a string")
+	}
+	return uri, nil
+}
+
+// GetMasterURI returns the URI of the master, as given by the master itself.
+func (node *defaultNode) GetMasterURI() (string, error) {
+	result, err := callRosAPI(node.masterURI, "getUri", node.qualifiedName)
+	if err != nil {
+		return "", err
+	}
+	uri, ok := result.(string)
+	if !ok {
+		return "", fmt.Errorf("result of 'getUri' is not a string")
+	}
+	return uri, nil
+}
+
+// GraphEventType is the kind of a graph change.
+type GraphEventType int
+
+const (
+	TopicAppeared GraphEventType = iota
+	TopicVanished
+	ServiceAppeared
+	ServiceVanished
+	NodeAppeared
+	NodeDied
+)
+
+var graphEventTypeNames = [...]string{
+	"TopicAppeared", "TopicVanished", "ServiceAppeared", "ServiceVanished", "NodeAppeared", "NodeDied",
+}
+
+func (t GraphEventType) String() string {
+	if t < 0 || int(t) >= len(graphEventTypeNames) {
+		return fmt.Sprintf("GraphEventType(%d)", t)
+	}
+	return graphEventTypeNames[t]
+}
+
+// GraphEvent is a change of the graph, Name is the name of the topic, service or node.
+type GraphEvent struct {
+	Type GraphEventType
+	Name string
+}
+
+// GraphWatcher polls the master and calls a callback for every change of the graph.
+type GraphWatcher interface {
+	Stop()
+}
+
+type defaultGraphWatcher struct {
+	node     *defaultNode
+	callback func(GraphEvent)
+	quitChan chan struct{}
+	stopOnce sync.Once
+}
+
+// NewGraphWatcher polls the master every period and calls callback for every change of the
+// graph since the previous poll. The first poll only records the initial state. Callbacks are
+// run by the spinners of the node's global callback queue.
+func (node *defaultNode) NewGraphWatcher(period time.Duration, callback func(GraphEvent)) GraphWatcher {
+	w := &defaultGraphWatcher{node: node, callback: callback, quitChan: make(chan struct{})}
+	node.spinnersMutex.Lock()
+	node.graphWatchers[w] = struct{}{}
+	node.spinnersMutex.Unlock()
+	go w.run(period)
+	return w
+}
+
+func (w *defaultGraphWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.quitChan)
+		w.node.spinnersMutex.Lock()
+		delete(w.node.graphWatchers, w)
+		w.node.spinnersMutex.Unlock()
+	})
+}
+
+func (w *defaultGraphWatcher) run(period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	var last *graphSnapshot
+	for {
+		state, err := w.node.GetSystemState()
+		if err != nil {
+			w.node.logger.Debugf("Graph watcher failed to get the system state: %v", err)
+		} else {
+			snapshot := newGraphSnapshot(state)
+			if last != nil {
+				for _, event := range last.diff(snapshot) {
+					ev := event
+					select {
+					case w.node.jobChan <- func() { w.callback(ev) }:
+					case <-w.quitChan:
+						return
+					}
+				}
+			}
+			last = snapshot
+		}
+		select {
+		case <-ticker.C:
+		case <-w.quitChan:
+			return
+		}
+	}
+}
+
+type graphSnapshot struct {
+	topics   []string
+	services []string
+	nodes    []string
+}
+
+func newGraphSnapshot(state *SystemState) *graphSnapshot {
+	services := make(map[string]bool)
+	for service := range state.Services {
+		services[service] = true
+	}
+	return &graphSnapshot{state.Topics(), sortedNames(services), state.Nodes()}
+}
+
+// diff returns the events changing s into next.
+func (s *graphSnapshot) diff(next *graphSnapshot) []GraphEvent {
+	var events []GraphEvent
+	add := func(names []string, typ GraphEventType) {
+		sort.Strings(names)
+		for _, name := range names {
+			events = append(events, GraphEvent{typ, name})
+		}
+	}
+	add(setDifference(next.nodes, s.nodes), NodeAppeared)
+	add(setDifference(next.topics, s.topics), TopicAppeared)
+	add(setDifference(next.services, s.services), ServiceAppeared)
+	add(setDifference(s.services, next.services), ServiceVanished)
+	add(setDifference(s.topics, next.topics), TopicVanished)
+	add(setDifference(s.nodes, next.nodes), NodeDied)
+	return events
+}
@@ -0,0 +1,128 @@
+package ros
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGraphQueries(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	node := newTestNode(t, m, "/graph_test")
+	defer node.Shutdown()
+	node.NewPublisher("/chatter", msgTestMessage)
+	node.NewSubscriber("/other", msgTestMessage, func(msg *testMessage) {})
+
+	state, err := node.GetSystemState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(state.Publishers["/chatter"], []string{"/graph_test"}) ||
+		!reflect.DeepEqual(state.Subscribers["/other"], []string{"/graph_test"}) ||
+		!reflect.DeepEqual(state.Services["/graph_test/get_loggers"], []string{"/graph_test"}) {
+		t.Error(state)
+	}
+	if nodes := state.Nodes(); !reflect.DeepEqual(nodes, []string{"/graph_test"}) {
+		t.Error(nodes)
+	}
+
+	types, err := node.GetTopicTypes()
+	if err != nil || !contains(topicNames(types), "/other") {
+		t.Error(types, err)
+	}
+	published, err := node.GetPublishedTopics("")
+	if err != nil || !contains(topicNames(published), "/chatter") || contains(topicNames(published), "/other") {
+		t.Error(published, err)
+	}
+	for _, topic := range published {
+		if topic.Name == "/chatter" && topic.Type != "std_msgs/String" {
+			t.Error(topic)
+		}
+	}
+
+	if uri, err := node.LookupNode("/graph_test"); err != nil || uri != node.xmlrpcURI {
+		t.Error(uri, err)
+	}
+	if _, err := node.LookupNode("/unknown"); err == nil {
+		t.Error("unknown node must fail")
+	}
+	remapped, err := newDefaultNode("/graph_remapped", []string{"__master:=" + m.URI(), "__ip:=127.0.0.1",
+		"__log:=" + filepath.Join(os.TempDir(), "rosgo_test_log"), "talker:=/graph_test", "news:=/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remapped.Shutdown()
+	if uri, err := remapped.LookupNode("talker"); err != nil || uri != node.xmlrpcURI {
+		t.Error(uri, err)
+	}
+	if published, err := remapped.GetPublishedTopics("news"); err != nil || !contains(topicNames(published), "/chatter") {
+		t.Error(published, err)
+	}
+	if s := NodeDied.String(); s != "NodeDied" {
+		t.Error(s)
+	}
+	if s := GraphEventType(42).String(); s != "GraphEventType(42)" {
+		t.Error(s)
+	}
+	if uri, err := node.GetMasterURI(); err != nil || uri != m.URI() {
+		t.Error(uri, err)
+	}
+}
+
+func topicNames(topics []TopicInfo) []string {
+	var names []string
+	for _, topic := range topics {
+		names = append(names, topic.Name)
+	}
+	return names
+}
+
+func TestGraphWatcher(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	node := newTestNode(t, m, "/graph_watcher")
+	defer node.Shutdown()
+
+	var events []GraphEvent
+	watcher := node.NewGraphWatcher(20*time.Millisecond, func(ev GraphEvent) {
+		events = append(events, ev)
+	})
+	defer watcher.Stop()
+	time.Sleep(50 * time.Millisecond)
+
+	other := newTestNode(t, m, "/graph_other")
+	other.NewPublisher("/news", msgTestMessage)
+	has := func(expected GraphEvent) func() bool {
+		return func() bool {
+			for _, ev := range events {
+				if ev == expected {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	if !spinUntil(node, 5*time.Second, has(GraphEvent{TopicAppeared, "/news"})) {
+		t.Fatal(events)
+	}
+	if !has(GraphEvent{NodeAppeared, "/graph_other"})() {
+		t.Error(events)
+	}
+	other.Shutdown()
+	if !spinUntil(node, 5*time.Second, has(GraphEvent{NodeDied, "/graph_other"})) {
+		t.Fatal(events)
+	}
+	if !has(GraphEvent{TopicVanished, "/news"})() {
+		t.Error(events)
+	}
+
+	watcher.Stop()
+	node.spinnersMutex.Lock()
+	if len(node.graphWatchers) != 0 {
+		t.Error("node still holds the stopped watcher")
+	}
+	node.spinnersMutex.Unlock()
+}
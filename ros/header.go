@@ -37,9 +37,16 @@ func readConnectionHeader(r io.Reader) ([]header, error) {
 		return nil, err
 	}
 
+	return decodeHeaderFields(buf)
+}
+
+// decodeHeaderFields parses header fields which are not prefixed by the total header length,
+// e.g. UDPROS headers carried in XML-RPC calls.
+func decodeHeaderFields(buf []byte) ([]header, error) {
+	headerSize := uint32(len(buf))
 	var done uint32 = 0
 	var headers []header
-	bufReader = bytes.NewBuffer(buf)
+	bufReader := bytes.NewBuffer(buf)
 	for {
 		if done == headerSize {
 			break
@@ -53,6 +60,9 @@ func readConnectionHeader(r io.Reader) ([]header, error) {
 		}
 		line := bufReader.Next(int(size))
 		sep := bytes.IndexByte(line, '=')
+		if sep < 0 {
+			return nil, fmt.Errorf("Header field without '='")
+		}
 		key := string(line[0:sep])
 		value := string(line[sep+1:])
 		headers = append(headers, header{key, value})
@@ -61,6 +71,18 @@ func readConnectionHeader(r io.Reader) ([]header, error) {
 	return headers, nil
 }
 
+// encodeHeaderFields serializes header fields without the total header length.
+func encodeHeaderFields(headers []header) []byte {
+	var buf bytes.Buffer
+	for _, h := range headers {
+		binary.Write(&buf, binary.LittleEndian, uint32(len(h.key)+len(h.value)+1))
+		buf.WriteString(h.key)
+		buf.WriteString("=")
+		buf.WriteString(h.value)
+	}
+	return buf.Bytes()
+}
+
 func writeConnectionHeader(headers []header, w io.Writer) error {
 	//var buf bytes.Buffer
 	var headerSize int
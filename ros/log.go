@@ -4,6 +4,10 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 )
 
 type LogLevel int
@@ -16,6 +20,18 @@ const (
 	LogLevelFatal
 )
 
+var logLevelNames = [...]string{"DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+// parseLogLevel parses a severity name, ignoring the case.
+func parseLogLevel(name string) (LogLevel, error) {
+	for i, levelName := range logLevelNames {
+		if strings.EqualFold(name, levelName) {
+			return LogLevel(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %s", name)
+}
+
 type Logger interface {
 	Severity() LogLevel
 	SetSeverity(severity LogLevel)
@@ -31,87 +47,206 @@ type Logger interface {
 	Fatalf(format string, v ...interface{})
 }
 
+// logRecord is a log message with the location it was logged from.
+type logRecord struct {
+	name     string // Name of the logger.
+	level    LogLevel
+	msg      string
+	file     string
+	function string
+	line     int
+}
+
+// logOutput receives the records of a logger, in addition to the standard logger.
+type logOutput interface {
+	write(record *logRecord)
+}
+
+// defaultLogger is a named logger. Loggers created by Child form a hierarchy under a root,
+// the severity of a logger is inherited from its parent until it's set.
 type defaultLogger struct {
-	severity LogLevel
+	name        string
+	parent      *defaultLogger
+	mutex       sync.RWMutex
+	severity    LogLevel
+	severitySet bool
+	outputs     []logOutput               // Only used by the root.
+	loggers     map[string]*defaultLogger // All the loggers by name, only used by the root.
 }
 
+// NewDefaultLogger creates a root logger named "ros.rosgo".
 func NewDefaultLogger() *defaultLogger {
 	logger := new(defaultLogger)
+	logger.name = "ros.rosgo"
 	logger.severity = LogLevelInfo
+	logger.severitySet = true
+	logger.loggers = map[string]*defaultLogger{logger.name: logger}
 	return logger
 }
 
+func (logger *defaultLogger) root() *defaultLogger {
+	for logger.parent != nil {
+		logger = logger.parent
+	}
+	return logger
+}
+
+// Child returns the logger named "<name of logger>.<name>", creating it if needed.
+func (logger *defaultLogger) Child(name string) Logger {
+	return logger.child(name)
+}
+
+func (logger *defaultLogger) child(name string) *defaultLogger {
+	root := logger.root()
+	fullName := logger.name + "." + name
+	root.mutex.Lock()
+	defer root.mutex.Unlock()
+	if child, ok := root.loggers[fullName]; ok {
+		return child
+	}
+	child := &defaultLogger{name: fullName, parent: logger}
+	root.loggers[fullName] = child
+	return child
+}
+
+// lookup returns the logger of the hierarchy with the full name, or nil.
+func (logger *defaultLogger) lookup(name string) *defaultLogger {
+	root := logger.root()
+	root.mutex.RLock()
+	defer root.mutex.RUnlock()
+	return root.loggers[name]
+}
+
+// all returns the loggers of the hierarchy sorted by name.
+func (logger *defaultLogger) all() []*defaultLogger {
+	root := logger.root()
+	root.mutex.RLock()
+	names := make([]string, 0, len(root.loggers))
+	for name := range root.loggers {
+		names = append(names, name)
+	}
+	root.mutex.RUnlock()
+	sort.Strings(names)
+	loggers := make([]*defaultLogger, 0, len(names))
+	for _, name := range names {
+		loggers = append(loggers, root.lookup(name))
+	}
+	return loggers
+}
+
+// Severity returns the severity of the logger, or the inherited one if it hasn't been set.
 func (logger *defaultLogger) Severity() LogLevel {
-	return logger.severity
+	for l := logger; ; l = l.parent {
+		l.mutex.RLock()
+		severity, set := l.severity, l.severitySet
+		l.mutex.RUnlock()
+		if set || l.parent == nil {
+			return severity
+		}
+	}
 }
 
 func (logger *defaultLogger) SetSeverity(severity LogLevel) {
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
 	logger.severity = severity
+	logger.severitySet = true
+}
+
+func (logger *defaultLogger) addOutput(output logOutput) {
+	root := logger.root()
+	root.mutex.Lock()
+	defer root.mutex.Unlock()
+	root.outputs = append(root.outputs, output)
+}
+
+func (logger *defaultLogger) enabled(level LogLevel) bool {
+	return int(logger.Severity()) <= int(level)
+}
+
+// log writes a message to the standard logger and the outputs. It must be called directly by
+// the logging methods, so that the record has the location of their caller.
+func (logger *defaultLogger) log(level LogLevel, msg string) {
+	log.Println(fmt.Sprintf("[%s] %s", logLevelNames[level], msg))
+
+	root := logger.root()
+	root.mutex.RLock()
+	outputs := root.outputs
+	root.mutex.RUnlock()
+	if len(outputs) == 0 {
+		return
+	}
+	record := &logRecord{name: logger.name, level: level, msg: msg}
+	if pc, file, line, ok := runtime.Caller(2); ok {
+		record.file = file
+		record.line = line
+		if f := runtime.FuncForPC(pc); f != nil {
+			record.function = f.Name()
+		}
+	}
+	for _, output := range outputs {
+		output.write(record)
+	}
 }
 
 func (logger *defaultLogger) Debug(v ...interface{}) {
-	if int(logger.severity) <= int(LogLevelDebug) {
-		msg := fmt.Sprintf("[DEBUG] %s", fmt.Sprint(v...))
-		log.Println(msg)
+	if logger.enabled(LogLevelDebug) {
+		logger.log(LogLevelDebug, fmt.Sprint(v...))
 	}
 }
 
 func (logger *defaultLogger) Debugf(format string, v ...interface{}) {
-	if int(logger.severity) <= int(LogLevelDebug) {
-		log.Printf("[DEBUG] "+format, v...)
+	if logger.enabled(LogLevelDebug) {
+		logger.log(LogLevelDebug, fmt.Sprintf(format, v...))
 	}
 }
 
 func (logger *defaultLogger) Info(v ...interface{}) {
-	if int(logger.severity) <= int(LogLevelInfo) {
-		msg := fmt.Sprintf("[INFO] %s", fmt.Sprint(v...))
-		log.Println(msg)
+	if logger.enabled(LogLevelInfo) {
+		logger.log(LogLevelInfo, fmt.Sprint(v...))
 	}
 }
 
 func (logger *defaultLogger) Infof(format string, v ...interface{}) {
-	if int(logger.severity) <= int(LogLevelInfo) {
-		log.Printf("[INFO] "+format, v...)
+	if logger.enabled(LogLevelInfo) {
+		logger.log(LogLevelInfo, fmt.Sprintf(format, v...))
 	}
 }
 
 func (logger *defaultLogger) Warn(v ...interface{}) {
-	if int(logger.severity) <= int(LogLevelWarn) {
-		msg := fmt.Sprintf("[WARN] %s", fmt.Sprint(v...))
-		log.Println(msg)
+	if logger.enabled(LogLevelWarn) {
+		logger.log(LogLevelWarn, fmt.Sprint(v...))
 	}
 }
 
 func (logger *defaultLogger) Warnf(format string, v ...interface{}) {
-	if int(logger.severity) <= int(LogLevelWarn) {
-		log.Printf("[WARN] "+format, v...)
+	if logger.enabled(LogLevelWarn) {
+		logger.log(LogLevelWarn, fmt.Sprintf(format, v...))
 	}
 }
 
 func (logger *defaultLogger) Error(v ...interface{}) {
-	if int(logger.severity) <= int(LogLevelError) {
-		msg := fmt.Sprintf("[ERROR] %s", fmt.Sprint(v...))
-		log.Println(msg)
+	if logger.enabled(LogLevelError) {
+		logger.log(LogLevelError, fmt.Sprint(v...))
 	}
 }
 
 func (logger *defaultLogger) Errorf(format string, v ...interface{}) {
-	if int(logger.severity) <= int(LogLevelError) {
-		log.Printf("[ERROR]"+format, v...)
+	if logger.enabled(LogLevelError) {
+		logger.log(LogLevelError, fmt.Sprintf(format, v...))
 	}
 }
 
 func (logger *defaultLogger) Fatal(v ...interface{}) {
-	if int(logger.severity) <= int(LogLevelFatal) {
-		msg := fmt.Sprintf("[FATAL] %s", fmt.Sprint(v...))
-		log.Println(msg)
+	if logger.enabled(LogLevelFatal) {
+		logger.log(LogLevelFatal, fmt.Sprint(v...))
 		os.Exit(1)
 	}
 }
 
 func (logger *defaultLogger) Fatalf(format string, v ...interface{}) {
-	if int(logger.severity) <= int(LogLevelFatal) {
-		log.Printf("[FATAL] "+format, v...)
+	if logger.enabled(LogLevelFatal) {
+		logger.log(LogLevelFatal, fmt.Sprintf(format, v...))
 		os.Exit(1)
 	}
 }
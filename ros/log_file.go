@@ -0,0 +1,137 @@
+package ros
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of the node's log file rotation.
+const (
+	DefaultLogFileMaxSize    = 100 * 1024 * 1024
+	DefaultLogFileMaxBackups = 10
+)
+
+// rotatingFile is a file renamed to <path>.1 when it would grow over maxSize bytes, with the
+// previous backups shifted up to <path>.<maxBackups>.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	mutex      sync.Mutex
+	file       *os.File
+	size       int64
+}
+
+func openRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+	if f.maxBackups > 0 {
+		for i := f.maxBackups - 1; i > 0; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+		}
+		if err := os.Rename(f.path, f.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(f.path); err != nil {
+		return err
+	}
+	return f.open()
+}
+
+func (f *rotatingFile) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// fileOutput writes the records of a logger to a file, in the format of rospy.
+type fileOutput struct {
+	file *rotatingFile
+}
+
+func (out *fileOutput) write(record *logRecord) {
+	now := time.Now()
+	fmt.Fprintf(out.file, "[%s][%s] %s,%03d: %s\n", record.name, logLevelNames[record.level],
+		now.Format("2006-01-02 15:04:05"), now.Nanosecond()/int(time.Millisecond), record.msg)
+}
+
+// logFilePath returns the path of the node's log file, <node name>-<pid>.log in the directory
+// of the run like roslaunch does.
+func (node *defaultNode) logFilePath() string {
+	dir := node.logDir
+	if runID, err := node.GetParam("/run_id"); err == nil {
+		if s, ok := runID.(string); ok && len(s) > 0 {
+			dir = filepath.Join(dir, s)
+		}
+	}
+	name := strings.Replace(strings.TrimPrefix(node.qualifiedName, "/"), "/", "_", -1)
+	return filepath.Join(dir, fmt.Sprintf("%s-%d.log", name, os.Getpid()))
+}
+
+// startLogFile writes the node's log messages to its log file as well.
+func (node *defaultNode) startLogFile(logger *defaultLogger) {
+	if node.logFileMaxSize < 0 {
+		return
+	}
+	path := node.logFilePath()
+	file, err := openRotatingFile(path, node.logFileMaxSize, node.logFileMaxBackups)
+	if err != nil {
+		logger.Warnf("Failed to open the log file %s: %v", path, err)
+		return
+	}
+	node.logFile = file
+	logger.addOutput(&fileOutput{file})
+}
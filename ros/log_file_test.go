@@ -0,0 +1,66 @@
+package ros
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rosgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log", "node.log")
+	f, err := openRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"aaaa\n", "bbbb\n", "cccc\n", "dddd\n", "eeee\n", "ffff\n", "gggg\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.Close()
+
+	expected := map[string]string{
+		path:        "gggg\n",
+		path + ".1": "eeee\nffff\n",
+		path + ".2": "cccc\ndddd\n",
+	}
+	for p, content := range expected {
+		data, err := ioutil.ReadFile(p)
+		if err != nil || string(data) != content {
+			t.Error(p, string(data), err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Error("only 2 backups must be kept")
+	}
+}
+
+func TestNodeLogFile(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	path := filepath.Join(os.TempDir(), "rosgo_test_log", fmt.Sprintf("ns_log_file_test-%d.log", os.Getpid()))
+	os.Remove(path)
+	node := newTestNode(t, m, "/ns/log_file_test")
+	if p := node.logFilePath(); p != path {
+		t.Fatal(p)
+	}
+	node.logger.child("publisher").Warn("written to the file")
+	node.Shutdown()
+	defer os.Remove(path)
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "[ros.rosgo.publisher][WARN] ") || !strings.HasSuffix(string(data), ": written to the file\n") {
+		t.Error(string(data))
+	}
+}
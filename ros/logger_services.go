@@ -0,0 +1,198 @@
+package ros
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Hand written roscpp/GetLoggers and roscpp/SetLoggerLevel, the ros package can't depend on
+// generated messages.
+type loggerMessageType struct {
+	name       string
+	md5sum     string
+	text       string
+	newMessage func() Message
+}
+
+func (t *loggerMessageType) Text() string        { return t.text }
+func (t *loggerMessageType) MD5Sum() string      { return t.md5sum }
+func (t *loggerMessageType) Name() string        { return t.name }
+func (t *loggerMessageType) NewMessage() Message { return t.newMessage() }
+
+type loggerServiceType struct {
+	name       string
+	md5sum     string
+	reqType    MessageType
+	resType    MessageType
+	newService func() Service
+}
+
+func (t *loggerServiceType) Name() string              { return t.name }
+func (t *loggerServiceType) MD5Sum() string            { return t.md5sum }
+func (t *loggerServiceType) RequestType() MessageType  { return t.reqType }
+func (t *loggerServiceType) ResponseType() MessageType { return t.resType }
+func (t *loggerServiceType) NewService() Service       { return t.newService() }
+
+var (
+	msgGetLoggersRequest = &loggerMessageType{
+		"roscpp/GetLoggersRequest",
+		"d41d8cd98f00b204e9800998ecf8427e",
+		"",
+		func() Message { return new(getLoggersRequest) },
+	}
+	msgGetLoggersResponse = &loggerMessageType{
+		"roscpp/GetLoggersResponse",
+		"32e97e85527d4678a8f9279894bb64b0",
+		"roscpp/Logger[] loggers\n\n" +
+			"================================================================================\n" +
+			"MSG: roscpp/Logger\nstring name\nstring level\n",
+		func() Message { return new(getLoggersResponse) },
+	}
+	srvGetLoggers = &loggerServiceType{
+		"roscpp/GetLoggers",
+		"32e97e85527d4678a8f9279894bb64b0",
+		msgGetLoggersRequest,
+		msgGetLoggersResponse,
+		func() Service { return new(getLoggersService) },
+	}
+
+	msgSetLoggerLevelRequest = &loggerMessageType{
+		"roscpp/SetLoggerLevelRequest",
+		"51da076440d78ca1684d36c868df61ea",
+		"string logger\nstring level\n",
+		func() Message { return new(setLoggerLevelRequest) },
+	}
+	msgSetLoggerLevelResponse = &loggerMessageType{
+		"roscpp/SetLoggerLevelResponse",
+		"d41d8cd98f00b204e9800998ecf8427e",
+		"",
+		func() Message { return new(setLoggerLevelResponse) },
+	}
+	srvSetLoggerLevel = &loggerServiceType{
+		"roscpp/SetLoggerLevel",
+		"51da076440d78ca1684d36c868df61ea",
+		msgSetLoggerLevelRequest,
+		msgSetLoggerLevelResponse,
+		func() Service { return new(setLoggerLevelService) },
+	}
+)
+
+// roscpp/Logger
+type loggerInfo struct {
+	Name  string `rosmsg:"name:string"`
+	Level string `rosmsg:"level:string"`
+}
+
+type getLoggersRequest struct{}
+
+func (m *getLoggersRequest) GetType() MessageType              { return msgGetLoggersRequest }
+func (m *getLoggersRequest) Serialize(buf *bytes.Buffer) error { return nil }
+func (m *getLoggersRequest) Deserialize(buf *Reader) error     { return nil }
+
+type getLoggersResponse struct {
+	Loggers []loggerInfo `rosmsg:"loggers:Logger[]"`
+}
+
+func (m *getLoggersResponse) GetType() MessageType {
+	return msgGetLoggersResponse
+}
+
+func (m *getLoggersResponse) Serialize(buf *bytes.Buffer) error {
+	binary.Write(buf, binary.LittleEndian, uint32(len(m.Loggers)))
+	for _, l := range m.Loggers {
+		writeString(buf, l.Name)
+		writeString(buf, l.Level)
+	}
+	return nil
+}
+
+func (m *getLoggersResponse) Deserialize(buf *Reader) error {
+	var size uint32
+	if err := binary.Read(buf, binary.LittleEndian, &size); err != nil {
+		return err
+	}
+	m.Loggers = make([]loggerInfo, int(size))
+	for i := range m.Loggers {
+		var err error
+		if m.Loggers[i].Name, err = readString(buf); err != nil {
+			return err
+		}
+		if m.Loggers[i].Level, err = readString(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type getLoggersService struct {
+	Request  getLoggersRequest
+	Response getLoggersResponse
+}
+
+func (s *getLoggersService) ReqMessage() Message { return &s.Request }
+func (s *getLoggersService) ResMessage() Message { return &s.Response }
+
+type setLoggerLevelRequest struct {
+	Logger string `rosmsg:"logger:string"`
+	Level  string `rosmsg:"level:string"`
+}
+
+func (m *setLoggerLevelRequest) GetType() MessageType {
+	return msgSetLoggerLevelRequest
+}
+
+func (m *setLoggerLevelRequest) Serialize(buf *bytes.Buffer) error {
+	writeString(buf, m.Logger)
+	writeString(buf, m.Level)
+	return nil
+}
+
+func (m *setLoggerLevelRequest) Deserialize(buf *Reader) error {
+	var err error
+	if m.Logger, err = readString(buf); err != nil {
+		return err
+	}
+	m.Level, err = readString(buf)
+	return err
+}
+
+type setLoggerLevelResponse struct{}
+
+func (m *setLoggerLevelResponse) GetType() MessageType              { return msgSetLoggerLevelResponse }
+func (m *setLoggerLevelResponse) Serialize(buf *bytes.Buffer) error { return nil }
+func (m *setLoggerLevelResponse) Deserialize(buf *Reader) error     { return nil }
+
+type setLoggerLevelService struct {
+	Request  setLoggerLevelRequest
+	Response setLoggerLevelResponse
+}
+
+func (s *setLoggerLevelService) ReqMessage() Message { return &s.Request }
+func (s *setLoggerLevelService) ResMessage() Message { return &s.Response }
+
+// advertiseLoggerServices lets tools like rosconsole and rqt_logger_level change the
+// severity of the node's loggers at runtime. The services answer even when the node doesn't
+// spin, or is stuck in a callback, which is when they are needed most.
+func (node *defaultNode) advertiseLoggerServices() {
+	node.NewServiceServer("~get_loggers", srvGetLoggers, func(srv *getLoggersService) error {
+		for _, logger := range node.logger.all() {
+			level := strings.ToLower(logLevelNames[logger.Severity()])
+			srv.Response.Loggers = append(srv.Response.Loggers, loggerInfo{logger.name, level})
+		}
+		return nil
+	}, ServiceServerConcurrent(1))
+	node.NewServiceServer("~set_logger_level", srvSetLoggerLevel, func(srv *setLoggerLevelService) error {
+		logger := node.logger.lookup(srv.Request.Logger)
+		if logger == nil {
+			return fmt.Errorf("no logger named %s", srv.Request.Logger)
+		}
+		level, err := parseLogLevel(srv.Request.Level)
+		if err != nil {
+			return err
+		}
+		logger.SetSeverity(level)
+		return nil
+	}, ServiceServerConcurrent(1))
+}
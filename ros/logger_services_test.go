@@ -0,0 +1,90 @@
+package ros
+
+import (
+	"testing"
+)
+
+func TestLoggerHierarchy(t *testing.T) {
+	root := NewDefaultLogger()
+	pub := root.child("publisher")
+	if pub != root.child("publisher") || pub.name != "ros.rosgo.publisher" {
+		t.Fatal("child loggers must be unique by name", pub.name)
+	}
+	session := pub.child("session")
+	root.SetSeverity(LogLevelWarn)
+	if session.Severity() != LogLevelWarn {
+		t.Error("severity must be inherited", session.Severity())
+	}
+	pub.SetSeverity(LogLevelDebug)
+	root.SetSeverity(LogLevelError)
+	if session.Severity() != LogLevelDebug || root.Severity() != LogLevelError {
+		t.Error(session.Severity(), root.Severity())
+	}
+	if root.lookup("ros.rosgo.publisher.session") != session || root.lookup("ros.rosgo.subscriber") != nil {
+		t.Error("lookup")
+	}
+	var names []string
+	for _, l := range session.all() {
+		names = append(names, l.name)
+	}
+	if len(names) != 3 || names[0] != "ros.rosgo" || names[2] != "ros.rosgo.publisher.session" {
+		t.Error(names)
+	}
+}
+
+func TestLoggerServices(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	node := newTestNode(t, m, "/logger_test")
+	defer node.Shutdown()
+	defer spinNode(node)()
+	node.NewPublisher("/chatter", msgTestMessage)
+
+	setLevel := node.NewServiceClient("/logger_test/set_logger_level", srvSetLoggerLevel)
+	srv := &setLoggerLevelService{Request: setLoggerLevelRequest{"ros.rosgo.publisher", "DEBUG"}}
+	if err := setLevel.Call(srv); err != nil {
+		t.Fatal(err)
+	}
+	srv.Request = setLoggerLevelRequest{"ros.rosgo.unknown", "debug"}
+	if err := setLevel.Call(srv); err == nil {
+		t.Error("unknown loggers can't be set")
+	}
+	srv.Request = setLoggerLevelRequest{"ros.rosgo", "verbose"}
+	if err := setLevel.Call(srv); err == nil {
+		t.Error("unknown severities can't be set")
+	}
+
+	getLoggers := node.NewServiceClient("/logger_test/get_loggers", srvGetLoggers)
+	var loggers getLoggersService
+	if err := getLoggers.Call(&loggers); err != nil {
+		t.Fatal(err)
+	}
+	levels := make(map[string]string)
+	for _, l := range loggers.Response.Loggers {
+		levels[l.Name] = l.Level
+	}
+	if levels["ros.rosgo"] != "info" || levels["ros.rosgo.publisher"] != "debug" || levels["ros.rosgo.service"] != "info" {
+		t.Error(levels)
+	}
+}
+
+func TestLoggerServicesWithoutSpin(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	node := newTestNode(t, m, "/logger_nospin_test")
+	defer node.Shutdown()
+
+	// The node never spins, the services must answer anyway.
+	setLevel := node.NewServiceClient("/logger_nospin_test/set_logger_level", srvSetLoggerLevel)
+	if err := setLevel.Call(&setLoggerLevelService{Request: setLoggerLevelRequest{"ros.rosgo", "warn"}}); err != nil {
+		t.Fatal(err)
+	}
+	getLoggers := node.NewServiceClient("/logger_nospin_test/get_loggers", srvGetLoggers)
+	var loggers getLoggersService
+	if err := getLoggers.Call(&loggers); err != nil {
+		t.Fatal(err)
+	}
+	if len(loggers.Response.Loggers) == 0 || loggers.Response.Loggers[0] != (loggerInfo{"ros.rosgo", "warn"}) {
+		t.Error(loggers.Response.Loggers)
+	}
+}
@@ -1,13 +1,18 @@
 package ros
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/fetchrobotics/rosgo/xmlrpc"
 )
 
 func callRosAPI(calleeURI string, method string, args ...interface{}) (interface{}, error) {
-	result, err := xmlrpc.Call(calleeURI, method, args...)
+	return callRosAPIContext(context.Background(), calleeURI, method, args...)
+}
+
+func callRosAPIContext(ctx context.Context, calleeURI string, method string, args ...interface{}) (interface{}, error) {
+	result, err := xmlrpc.CallContext(ctx, calleeURI, method, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -33,12 +38,21 @@ func callRosAPI(calleeURI string, method string, args ...interface{}) (interface
 	value = xs[2]
 
 	if code != APIStatusSuccess {
-		err := fmt.Errorf("ROS Master API call failed with code %d: %s", code, message)
-		return nil, err
+		return nil, &rosAPIError{code, message}
 	}
 	return value, nil
 }
 
+// rosAPIError is returned for a call which the callee answered with a failure status.
+type rosAPIError struct {
+	code    int32
+	message string
+}
+
+func (e *rosAPIError) Error() string {
+	return fmt.Sprintf("ROS Master API call failed with code %d: %s", e.code, e.message)
+}
+
 // Build XMLRPC ready array from ROS API result triplet.
 func buildRosAPIResult(code int32, message string, value interface{}) interface{} {
 	result := make([]interface{}, 3)
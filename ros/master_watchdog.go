@@ -0,0 +1,158 @@
+package ros
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// DefaultMasterCheckPeriod is the default period of the master watchdog.
+const DefaultMasterCheckPeriod = 5 * time.Second
+
+// watchMaster checks the master every period, and registers the node again once the master
+// restarted.
+func (node *defaultNode) watchMaster(period time.Duration, quitChan chan struct{}) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	state := masterState{registered: true}
+	for {
+		select {
+		case <-ticker.C:
+		case <-quitChan:
+			return
+		}
+		node.checkMaster(&state)
+	}
+}
+
+// masterState is what the watchdog knows of the master between two checks.
+type masterState struct {
+	pid        int32
+	registered bool
+}
+
+// checkMaster registers the node again if the master restarted. A restart is noticed by a new
+// pid of the master, by failed calls followed by a recovery, or by a master which doesn't know
+// the node anymore, since a master running in the node's process keeps the same pid.
+func (node *defaultNode) checkMaster(state *masterState) {
+	result, err := callRosAPI(node.masterURI, "getPid", node.qualifiedName)
+	if err != nil {
+		if state.registered {
+			node.logger.Warnf("Lost the master %s: %v", node.masterURI, err)
+		}
+		state.registered = false
+		return
+	}
+	pid, _ := result.(int32)
+	if state.registered && (state.pid == 0 || pid == state.pid) {
+		known, err := node.knownByMaster()
+		if err != nil {
+			node.logger.Warnf("Lost the master %s: %v", node.masterURI, err)
+			state.registered = false
+			return
+		}
+		if known {
+			state.pid = pid
+			return
+		}
+	}
+	node.logger.Infof("Master %s restarted, registering again", node.masterURI)
+	if err := node.registerAgain(); err != nil {
+		node.logger.Warnf("Failed to register again: %v", err)
+		state.registered = false
+		return
+	}
+	state.registered = true
+	state.pid = pid
+}
+
+// knownByMaster returns false if the node registered anything but the master answers that it
+// doesn't know the node. Other failures are returned as errors.
+func (node *defaultNode) knownByMaster() (bool, error) {
+	node.publishersMutex.RLock()
+	registrations := len(node.publishers)
+	node.publishersMutex.RUnlock()
+	node.subscribersMutex.RLock()
+	registrations += len(node.subscribers)
+	node.subscribersMutex.RUnlock()
+	node.serversMutex.RLock()
+	registrations += len(node.servers)
+	node.serversMutex.RUnlock()
+	registrations += len(node.params.names())
+	if registrations == 0 {
+		return true, nil
+	}
+	_, err := callRosAPI(node.masterURI, "lookupNode", node.qualifiedName, node.qualifiedName)
+	if apiErr, ok := err.(*rosAPIError); ok && apiErr.code == APIStatusError {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// registerAgain sets the private parameters of the node's arguments again, and registers the
+// publishers, subscribers, services and subscribed parameters with the master.
+func (node *defaultNode) registerAgain() error {
+	if err := node.setPrivateParams(); err != nil {
+		return fmt.Errorf("failed to set private parameters: %v", err)
+	}
+
+	node.publishersMutex.RLock()
+	var pubs []*defaultPublisher
+	for _, pub := range node.publishers {
+		pubs = append(pubs, pub)
+	}
+	node.publishersMutex.RUnlock()
+	for _, pub := range pubs {
+		_, err := callRosAPI(node.masterURI, "registerPublisher", node.qualifiedName, pub.topic, pub.msgType.Name(), node.xmlrpcURI)
+		if err != nil {
+			return fmt.Errorf("failed to register publisher of %s: %v", pub.topic, err)
+		}
+	}
+
+	node.subscribersMutex.RLock()
+	var subs []*defaultSubscriber
+	for _, sub := range node.subscribers {
+		subs = append(subs, sub)
+	}
+	node.subscribersMutex.RUnlock()
+	for _, sub := range subs {
+		result, err := callRosAPI(node.masterURI, "registerSubscriber", node.qualifiedName, sub.topic, sub.msgType.Name(), node.xmlrpcURI)
+		if err != nil {
+			return fmt.Errorf("failed to register subscriber of %s: %v", sub.topic, err)
+		}
+		list, _ := result.([]interface{})
+		publishers := []string{}
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				publishers = append(publishers, s)
+			}
+		}
+		sub.pubListChan <- publishers
+	}
+
+	node.serversMutex.RLock()
+	var servers []*defaultServiceServer
+	for _, server := range node.servers {
+		servers = append(servers, server)
+	}
+	node.serversMutex.RUnlock()
+	for _, server := range servers {
+		_, err := callRosAPI(node.masterURI, "registerService", node.qualifiedName, server.service, server.rosrpcAddr, node.xmlrpcURI)
+		if err != nil {
+			return fmt.Errorf("failed to register service %s: %v", server.service, err)
+		}
+	}
+
+	// The parameters of the new master may differ, changes are handled like updates.
+	for _, name := range node.params.names() {
+		value, err := callRosAPI(node.masterURI, "subscribeParam", node.qualifiedName, node.xmlrpcURI, name)
+		if err != nil {
+			return fmt.Errorf("failed to subscribe parameter %s: %v", name, err)
+		}
+		if cached, ok, _ := node.params.get(name); ok && reflect.DeepEqual(cached, paramValue(value)) {
+			continue
+		}
+		node.queueParamCallbacks(node.params.update(name, value))
+	}
+	return nil
+}
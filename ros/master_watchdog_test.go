@@ -0,0 +1,179 @@
+package ros
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fetchrobotics/rosgo/master"
+	"github.com/fetchrobotics/rosgo/xmlrpc"
+)
+
+// registeredNames returns the topics or services of the master state with node registered.
+func registeredNames(state interface{}, node string) map[string]bool {
+	names := make(map[string]bool)
+	entries, _ := state.([]interface{})
+	for _, entry := range entries {
+		pair := entry.([]interface{})
+		for _, n := range pair[1].([]interface{}) {
+			if n == node {
+				names[pair[0].(string)] = true
+			}
+		}
+	}
+	return names
+}
+
+func TestMasterRestart(t *testing.T) {
+	m := newTestMaster(t)
+	node := newTestNode(t, m, "/watchdog_test", NodeMasterCheckPeriod(50*time.Millisecond))
+	defer node.Shutdown()
+	node.NewPublisher("/chatter", msgTestMessage)
+	node.NewSubscriber("/other", msgTestMessage, func(msg *testMessage) {})
+	node.NewServiceServer("/echo", srvTestService, echoHandler)
+	node.SetParam("/gain", int32(1))
+	var gain interface{}
+	if err := node.SubscribeParam("/gain", func(name string, value interface{}) {
+		gain = value
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	u, _ := url.Parse(m.URI())
+	m.Shutdown()
+	http.DefaultTransport.(*http.Transport).CloseIdleConnections()
+	time.Sleep(200 * time.Millisecond)
+	m, err := master.NewMaster(u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Shutdown()
+	if _, err := callRosAPI(m.URI(), "setParam", "/test", "/gain", int32(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !spinUntil(node, 5*time.Second, func() bool { return gain == int32(2) }) {
+		t.Fatal("parameter was not subscribed again", gain)
+	}
+	state, err := callRosAPI(m.URI(), "getSystemState", "/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lists := state.([]interface{})
+	if !registeredNames(lists[0], "/watchdog_test")["/chatter"] ||
+		!registeredNames(lists[1], "/watchdog_test")["/other"] ||
+		!registeredNames(lists[2], "/watchdog_test")["/echo"] {
+		t.Error(state)
+	}
+}
+
+// restartMaster shuts the master down and starts a new one at the same address.
+func restartMaster(t *testing.T, m *master.Master) *master.Master {
+	u, _ := url.Parse(m.URI())
+	m.Shutdown()
+	http.DefaultTransport.(*http.Transport).CloseIdleConnections()
+	m, err := master.NewMaster(u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// A master running in the same process keeps the pid, and a quick restart may not be seen as
+// failed calls.
+func TestInProcessMasterRestart(t *testing.T) {
+	m := newTestMaster(t)
+	logDir := filepath.Join(os.TempDir(), "rosgo_test_log")
+	node, err := newDefaultNode("/restart_test", []string{"__master:=" + m.URI(), "__ip:=127.0.0.1", "__log:=" + logDir, "_rate:=10"},
+		NodeMasterCheckPeriod(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Shutdown()
+	node.NewPublisher("/chatter", msgTestMessage)
+
+	state := masterState{registered: true}
+	node.checkMaster(&state)
+	m = restartMaster(t, m)
+	defer m.Shutdown()
+	node.checkMaster(&state)
+
+	if rate, err := callRosAPI(m.URI(), "getParam", "/test", "/restart_test/rate"); err != nil || rate != int32(10) {
+		t.Error("private parameters must be set again", rate, err)
+	}
+	systemState, err := callRosAPI(m.URI(), "getSystemState", "/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !registeredNames(systemState.([]interface{})[0], "/restart_test")["/chatter"] {
+		t.Error(systemState)
+	}
+}
+
+// Only a master answering that it doesn't know the node is a restart, other failures of
+// lookupNode mean the master is lost.
+func TestLookupNodeFailure(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	node := newTestNode(t, m, "/lookup_test", NodeMasterCheckPeriod(0))
+	defer node.Shutdown()
+	node.NewPublisher("/chatter", msgTestMessage)
+
+	var mutex sync.Mutex
+	var lookup func() (interface{}, error)
+	registrations := 0
+	fake := httptest.NewServer(xmlrpc.NewHandler(map[string]xmlrpc.Method{
+		"getPid": func(callerID string) (interface{}, error) {
+			return buildRosAPIResult(APIStatusSuccess, "", int32(1)), nil
+		},
+		"lookupNode": func(callerID string, name string) (interface{}, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			return lookup()
+		},
+		"registerPublisher": func(callerID string, topic string, topicType string, callerAPI string) (interface{}, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			registrations++
+			return buildRosAPIResult(APIStatusSuccess, "", []interface{}{}), nil
+		},
+	}))
+	defer fake.Close()
+	masterURI := node.masterURI
+	node.masterURI = fake.URL
+	defer func() { node.masterURI = masterURI }()
+
+	check := func(result func() (interface{}, error), restart bool) masterState {
+		mutex.Lock()
+		lookup = result
+		registrations = 0
+		mutex.Unlock()
+		state := masterState{pid: 1, registered: true}
+		node.checkMaster(&state)
+		mutex.Lock()
+		defer mutex.Unlock()
+		if (registrations > 0) != restart {
+			t.Error("registered", registrations, "publishers")
+		}
+		return state
+	}
+	if state := check(func() (interface{}, error) {
+		return buildRosAPIResult(APIStatusFailure, "busy", ""), nil
+	}, false); state.registered {
+		t.Error("a failed lookupNode must lose the master")
+	}
+	if state := check(func() (interface{}, error) {
+		return nil, errors.New("internal error")
+	}, false); state.registered {
+		t.Error("a failed lookupNode must lose the master")
+	}
+	check(func() (interface{}, error) {
+		return buildRosAPIResult(APIStatusError, "unknown node [/lookup_test]", ""), nil
+	}, true)
+}
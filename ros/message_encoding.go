@@ -0,0 +1,356 @@
+package ros
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The JSON and YAML encodings of messages follow rosbridge and rostopic: fields are named as in
+// the message definition and keep its order, time and duration are {secs, nsecs}, uint8[] and
+// char[] are base64 strings. Fields are found by their rosmsg tags, like generated messages.
+
+// MarshalMessageJSON returns the JSON encoding of msg. Float values that aren't finite are null.
+func MarshalMessageJSON(msg Message) ([]byte, error) {
+	return json.Marshal(encodeValue(reflect.ValueOf(msg)))
+}
+
+// UnmarshalMessageJSON sets the fields of msg from their JSON encoding. Fields missing in data
+// are left unchanged.
+func UnmarshalMessageJSON(data []byte, msg Message) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+	return decodeMessage(msg, value)
+}
+
+// MarshalMessageYAML returns the YAML encoding of msg, like rostopic echo prints it.
+func MarshalMessageYAML(msg Message) ([]byte, error) {
+	return yaml.Marshal(encodeValue(reflect.ValueOf(msg)))
+}
+
+// UnmarshalMessageYAML sets the fields of msg from their YAML encoding, like the arguments of
+// rostopic pub. Fields missing in data are left unchanged.
+func UnmarshalMessageYAML(data []byte, msg Message) error {
+	var value interface{}
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	return decodeMessage(msg, value)
+}
+
+var (
+	timeType     = reflect.TypeOf(Time{})
+	durationType = reflect.TypeOf(Duration{})
+)
+
+// messageFields is a message encoded as an object or a mapping, in the order of its fields.
+type messageFields []messageField
+
+type messageField struct {
+	name  string
+	value interface{}
+}
+
+func (fields messageFields) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(f.name)
+		buf.Write(name)
+		buf.WriteByte(':')
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (fields messageFields) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, f := range fields {
+		value := new(yaml.Node)
+		if err := value.Encode(f.value); err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: f.name}, value)
+	}
+	return node, nil
+}
+
+// floatValue is a float32 or a float64, printed with the precision of its type.
+type floatValue struct {
+	value float64
+	bits  int
+}
+
+func (f floatValue) MarshalJSON() ([]byte, error) {
+	if math.IsNaN(f.value) || math.IsInf(f.value, 0) {
+		return []byte("null"), nil
+	}
+	return []byte(strconv.FormatFloat(f.value, 'g', -1, f.bits)), nil
+}
+
+func (f floatValue) MarshalYAML() (interface{}, error) {
+	if f.bits == 32 {
+		return float32(f.value), nil
+	}
+	return f.value, nil
+}
+
+// rosmsgName returns the name of the message field in the rosmsg tag of a struct field.
+func rosmsgName(field reflect.StructField) (string, bool) {
+	tag, ok := field.Tag.Lookup("rosmsg")
+	if !ok || len(field.PkgPath) > 0 {
+		return "", false
+	}
+	return strings.SplitN(tag, ":", 2)[0], true
+}
+
+func isByteArray(t reflect.Type) bool {
+	return (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() == reflect.Uint8
+}
+
+func encodeValue(v reflect.Value) interface{} {
+	switch {
+	case v.Type() == timeType || v.Type() == durationType:
+		return messageFields{{"secs", v.FieldByName("Sec").Interface()}, {"nsecs", v.FieldByName("NSec").Interface()}}
+	case isByteArray(v.Type()):
+		data := make([]byte, v.Len())
+		reflect.Copy(reflect.ValueOf(data), v)
+		return base64.StdEncoding.EncodeToString(data)
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		return encodeValue(v.Elem())
+	case reflect.Struct:
+		fields := messageFields{}
+		for i := 0; i < v.NumField(); i++ {
+			if name, ok := rosmsgName(v.Type().Field(i)); ok {
+				fields = append(fields, messageField{name, encodeValue(v.Field(i))})
+			}
+		}
+		return fields
+	case reflect.Slice, reflect.Array:
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = encodeValue(v.Index(i))
+		}
+		return items
+	case reflect.Float32:
+		return floatValue{v.Float(), 32}
+	case reflect.Float64:
+		return floatValue{v.Float(), 64}
+	default:
+		return v.Interface()
+	}
+}
+
+func decodeMessage(msg Message, value interface{}) error {
+	v := reflect.ValueOf(msg)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("can't decode into a %T", msg)
+	}
+	return decodeValue(v.Elem(), value, "")
+}
+
+// decodeValue sets v from a value decoded by encoding/json or yaml, path is the name of the
+// field in errors.
+func decodeValue(v reflect.Value, value interface{}, path string) error {
+	if v.Type() == timeType || v.Type() == durationType {
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return decodeError(path, "{secs, nsecs}", value)
+		}
+		for name, field := range fields {
+			var target reflect.Value
+			switch name {
+			case "secs":
+				target = v.FieldByName("Sec")
+			case "nsecs":
+				target = v.FieldByName("NSec")
+			default:
+				return fmt.Errorf("%s: unknown field %s", fieldPath(path), name)
+			}
+			if err := decodeValue(target, field, path+"."+name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if s, ok := value.(string); ok && isByteArray(v.Type()) {
+		data, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return fmt.Errorf("%s: %v", fieldPath(path), err)
+		}
+		if v.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(v.Type(), len(data), len(data)))
+		} else if len(data) != v.Len() {
+			return fmt.Errorf("%s: expected %d bytes, got %d", fieldPath(path), v.Len(), len(data))
+		}
+		reflect.Copy(v, reflect.ValueOf(data))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return decodeError(path, "an object", value)
+		}
+		return decodeFields(v, fields, path)
+	case reflect.Slice, reflect.Array:
+		items, ok := value.([]interface{})
+		if !ok {
+			return decodeError(path, "an array", value)
+		}
+		if v.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(v.Type(), len(items), len(items)))
+		} else if len(items) != v.Len() {
+			return fmt.Errorf("%s: expected %d items, got %d", fieldPath(path), v.Len(), len(items))
+		}
+		for i, item := range items {
+			if err := decodeValue(v.Index(i), item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.String:
+		s, ok := value.(string)
+		if !ok {
+			return decodeError(path, "a string", value)
+		}
+		v.SetString(s)
+		return nil
+	case reflect.Bool:
+		b, ok := value.(bool)
+		if !ok {
+			return decodeError(path, "a boolean", value)
+		}
+		v.SetBool(b)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		if value == nil {
+			v.SetFloat(math.NaN())
+			return nil
+		}
+		f, ok := toFloat(value)
+		if !ok {
+			return decodeError(path, "a number", value)
+		}
+		v.SetFloat(f)
+		return nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := toInt(value)
+		if !ok || v.OverflowInt(i) {
+			return decodeError(path, "an "+v.Type().String(), value)
+		}
+		v.SetInt(i)
+		return nil
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, ok := toUint(value)
+		if !ok || v.OverflowUint(u) {
+			return decodeError(path, "a "+v.Type().String(), value)
+		}
+		v.SetUint(u)
+		return nil
+	default:
+		return fmt.Errorf("%s: can't decode a %s", fieldPath(path), v.Type())
+	}
+}
+
+// decodeFields sets the fields of the message v, unknown fields are errors.
+func decodeFields(v reflect.Value, fields map[string]interface{}, path string) error {
+	known := make(map[string]bool)
+	for i := 0; i < v.NumField(); i++ {
+		name, ok := rosmsgName(v.Type().Field(i))
+		if !ok {
+			continue
+		}
+		known[name] = true
+		if value, ok := fields[name]; ok {
+			if err := decodeValue(v.Field(i), value, path+"."+name); err != nil {
+				return err
+			}
+		}
+	}
+	for name := range fields {
+		if !known[name] {
+			return fmt.Errorf("%s: unknown field %s", fieldPath(path), name)
+		}
+	}
+	return nil
+}
+
+func fieldPath(path string) string {
+	if len(path) == 0 {
+		return "message"
+	}
+	return strings.TrimPrefix(path, ".")
+}
+
+func decodeError(path string, expected string, value interface{}) error {
+	return fmt.Errorf("%s: expected %s, got %v", fieldPath(path), expected, value)
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch x := value.(type) {
+	case json.Number:
+		f, err := x.Float64()
+		return f, err == nil
+	case float64:
+		return x, true
+	case int:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	}
+	return 0, false
+}
+
+func toInt(value interface{}) (int64, bool) {
+	switch x := value.(type) {
+	case json.Number:
+		i, err := strconv.ParseInt(string(x), 10, 64)
+		return i, err == nil
+	case int:
+		return int64(x), true
+	case int64:
+		return x, true
+	case uint64:
+		return int64(x), x <= math.MaxInt64
+	}
+	return 0, false
+}
+
+func toUint(value interface{}) (uint64, bool) {
+	switch x := value.(type) {
+	case json.Number:
+		u, err := strconv.ParseUint(string(x), 10, 64)
+		return u, err == nil
+	case int:
+		return uint64(x), x >= 0
+	case int64:
+		return uint64(x), x >= 0
+	case uint64:
+		return x, true
+	}
+	return 0, false
+}
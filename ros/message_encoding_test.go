@@ -0,0 +1,125 @@
+package ros
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type encodingTestPoint struct {
+	X float64 `rosmsg:"x:float64"`
+	Y float64 `rosmsg:"y:float64"`
+}
+
+type encodingTestMessage struct {
+	Header  logMessageHeader    `rosmsg:"header:Header"`
+	Flag    bool                `rosmsg:"flag:bool"`
+	Small   int8                `rosmsg:"small:int8"`
+	Big     uint64              `rosmsg:"big:uint64"`
+	Ratio   float32             `rosmsg:"ratio:float32"`
+	Range   float64             `rosmsg:"range:float64"`
+	Timeout Duration            `rosmsg:"timeout:duration"`
+	Data    []uint8             `rosmsg:"data:uint8[]"`
+	Code    [2]uint8            `rosmsg:"code:uint8[2]"`
+	Values  []int16             `rosmsg:"values:int16[]"`
+	Points  []encodingTestPoint `rosmsg:"points:Point[]"`
+	cache   int
+}
+
+func (m *encodingTestMessage) GetType() MessageType              { return nil }
+func (m *encodingTestMessage) Serialize(buf *bytes.Buffer) error { return nil }
+func (m *encodingTestMessage) Deserialize(buf *Reader) error     { return nil }
+
+func newEncodingTestMessage() *encodingTestMessage {
+	return &encodingTestMessage{
+		Header:  logMessageHeader{3, NewTime(10, 20), "map"},
+		Flag:    true,
+		Small:   -5,
+		Big:     math.MaxUint64,
+		Ratio:   0.1,
+		Range:   math.Inf(1),
+		Timeout: NewDuration(1, 500000000),
+		Data:    []uint8{1, 2, 3},
+		Code:    [2]uint8{255, 0},
+		Values:  []int16{-1, 2},
+		Points:  []encodingTestPoint{{1, 2}, {3.5, 4}},
+	}
+}
+
+func TestMarshalMessageJSON(t *testing.T) {
+	data, err := MarshalMessageJSON(newEncodingTestMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"header":{"seq":3,"stamp":{"secs":10,"nsecs":20},"frame_id":"map"},"flag":true,` +
+		`"small":-5,"big":18446744073709551615,"ratio":0.1,"range":null,"timeout":{"secs":1,"nsecs":500000000},` +
+		`"data":"AQID","code":"/wA=","values":[-1,2],"points":[{"x":1,"y":2},{"x":3.5,"y":4}]}`
+	if string(data) != expected {
+		t.Fatalf("\n%s\n%s", data, expected)
+	}
+
+	var msg encodingTestMessage
+	if err := UnmarshalMessageJSON(data, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsNaN(msg.Range) {
+		t.Error("null must be decoded as NaN", msg.Range)
+	}
+	msg.Range = math.Inf(1)
+	if !reflect.DeepEqual(&msg, newEncodingTestMessage()) {
+		t.Errorf("%+v", msg)
+	}
+}
+
+func TestMarshalMessageYAML(t *testing.T) {
+	data, err := MarshalMessageYAML(newEncodingTestMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "header:\n    seq: 3\n    stamp:\n        secs: 10\n        nsecs: 20\n") ||
+		!strings.Contains(string(data), "\nratio: 0.1\nrange: .inf\n") || !strings.Contains(string(data), "\ndata: AQID\n") {
+		t.Fatal(string(data))
+	}
+
+	var msg encodingTestMessage
+	if err := UnmarshalMessageYAML(data, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&msg, newEncodingTestMessage()) {
+		t.Errorf("%+v", msg)
+	}
+
+	// Like the arguments of rostopic pub, with missing fields left unchanged.
+	msg = encodingTestMessage{Small: 7}
+	if err := UnmarshalMessageYAML([]byte("{header: {stamp: {secs: 5}}, data: [4, 5], points: [{x: 1}]}"), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Header.Stamp != NewTime(5, 0) || msg.Small != 7 || !bytes.Equal(msg.Data, []byte{4, 5}) || msg.Points[0].X != 1 {
+		t.Errorf("%+v", msg)
+	}
+}
+
+func TestUnmarshalMessageErrors(t *testing.T) {
+	var tests = []struct {
+		data     string
+		expected string
+	}{
+		{`{"small": 128}`, "small: expected an int8, got 128"},
+		{`{"big": -1}`, "big: expected a uint64, got -1"},
+		{`{"flag": 1}`, "flag: expected a boolean, got 1"},
+		{`{"code": [1, 2, 3]}`, "code: expected 2 items, got 3"},
+		{`{"points": [{"z": 1}]}`, "points[0]: unknown field z"},
+		{`{"header": {"stamp": 12}}`, "header.stamp: expected {secs, nsecs}, got 12"},
+		{`{"cache": 1}`, "message: unknown field cache"},
+		{`[]`, "message: expected an object, got []"},
+	}
+	for _, test := range tests {
+		var msg encodingTestMessage
+		err := UnmarshalMessageJSON([]byte(test.data), &msg)
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("%s: %v", test.data, err)
+		}
+	}
+}
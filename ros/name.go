@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+
+	"github.com/fetchrobotics/rosgo/internal/names"
 )
 
 const (
@@ -73,21 +75,7 @@ func isPrivateName(name string) bool {
 
 // Remove sequential seperater
 func canonicalizeName(name string) string {
-	if name == GlobalNS {
-		return name
-	} else {
-		components := []string{}
-		for _, word := range strings.Split(name, Sep) {
-			if len(word) > 0 {
-				components = append(components, word)
-			}
-		}
-		if name[0:1] == GlobalNS {
-			return GlobalNS + strings.Join(components, Sep)
-		} else {
-			return strings.Join(components, Sep)
-		}
-	}
+	return names.Canonicalize(name)
 }
 
 type NameResolver struct {
@@ -1,7 +1,7 @@
 package ros
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/fetchrobotics/rosgo/xmlrpc"
+	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -51,37 +52,53 @@ func processArguments(args []string) (NameMap, NameMap, NameMap, []string) {
 // *defaultNode implements Node interface
 // a defaultNode instance must be accessed in user goroutine.
 type defaultNode struct {
-	name             string
-	namespace        string
-	qualifiedName    string
-	masterURI        string
-	xmlrpcURI        string
-	xmlrpcListener   net.Listener
-	xmlrpcHandler    *xmlrpc.Handler
-	subscribers      map[string]*defaultSubscriber
-	subscribersMutex sync.RWMutex
-	publishers       map[string]*defaultPublisher
-	publishersMutex  sync.RWMutex
-	servers          map[string]*defaultServiceServer
-	serversMutex     sync.RWMutex
-	jobChan          chan func()
-	interruptChan    chan os.Signal
-	logger           Logger
-	ok               bool
-	okMutex          sync.RWMutex
-	waitGroup        sync.WaitGroup
-	logDir           string
-	hostname         string
-	listenIP         string
-	homeDir          string
-	nameResolver     *NameResolver
-	nonRosArgs       []string
-	srvClientOpts    []ServiceClientOption
-	srvServerOpts    []ServiceServerOption
+	name              string
+	namespace         string
+	qualifiedName     string
+	masterURI         string
+	xmlrpcURI         string
+	xmlrpcListener    net.Listener
+	xmlrpcHandler     *xmlrpc.Handler
+	subscribers       map[string]*defaultSubscriber
+	subscribersMutex  sync.RWMutex
+	publishers        map[string]*defaultPublisher
+	publishersMutex   sync.RWMutex
+	servers           map[string]*defaultServiceServer
+	serversMutex      sync.RWMutex
+	jobChan           chan func()
+	interruptChan     chan os.Signal
+	logger            *defaultLogger
+	ok                bool
+	okMutex           sync.RWMutex
+	waitGroup         sync.WaitGroup
+	logDir            string
+	hostname          string
+	listenIP          string
+	homeDir           string
+	nameResolver      *NameResolver
+	nonRosArgs        []string
+	srvClientOpts     []ServiceClientOption
+	srvServerOpts     []ServiceServerOption
+	busStats          *busStats
+	params            *paramCache
+	privateParams     NameMap // _param:=value arguments, set again after a master restart.
+	spinners          map[*asyncSpinner]struct{}
+	timers            map[*defaultTimer]struct{}
+	graphWatchers     map[*defaultGraphWatcher]struct{}
+	spinnersMutex     sync.Mutex // Guards spinners, timers and graphWatchers, which hold the running ones.
+	rosout            *rosoutOutput
+	logFile           *rotatingFile
+	logFileMaxSize    int64
+	logFileMaxBackups int
+	masterCheckPeriod time.Duration
+	watchdogQuitChan  chan struct{}
 }
 
 func newDefaultNode(name string, args []string, opts ...NodeOption) (*defaultNode, error) {
 	node := new(defaultNode)
+	node.logFileMaxSize = DefaultLogFileMaxSize
+	node.logFileMaxBackups = DefaultLogFileMaxBackups
+	node.masterCheckPeriod = DefaultMasterCheckPeriod
 	for _, opt := range opts {
 		opt(node)
 	}
@@ -148,6 +165,11 @@ func newDefaultNode(name string, args []string, opts ...NodeOption) (*defaultNod
 
 	node.subscribers = make(map[string]*defaultSubscriber)
 	node.publishers = make(map[string]*defaultPublisher)
+	node.busStats = newBusStats()
+	node.params = newParamCache()
+	node.spinners = make(map[*asyncSpinner]struct{})
+	node.timers = make(map[*defaultTimer]struct{})
+	node.graphWatchers = make(map[*defaultGraphWatcher]struct{})
 	node.servers = make(map[string]*defaultServiceServer)
 	node.interruptChan = make(chan os.Signal)
 	node.ok = true
@@ -155,37 +177,23 @@ func newDefaultNode(name string, args []string, opts ...NodeOption) (*defaultNod
 	logger := NewDefaultLogger()
 	node.logger = logger
 
-	// Install signal handler
-	signal.Notify(node.interruptChan, os.Interrupt)
-	go func() {
-		<-node.interruptChan
-		logger.Info("Interrupted")
-		node.okMutex.Lock()
-		node.ok = false
-		node.okMutex.Unlock()
-	}()
-
 	node.jobChan = make(chan func(), 100)
 
 	logger.Debugf("Master URI = %s", node.masterURI)
 
-	// Set parameters set by arguments
-	for k, v := range params {
-		_, err := callRosAPI(node.masterURI, "setParam", node.qualifiedName, k, v)
-		if err != nil {
-			return nil, err
-		}
+	node.privateParams = params
+	if err := node.setPrivateParams(); err != nil {
+		return nil, err
 	}
 
 	listener, err := net.Listen("tcp", ":0")
 	if err != nil {
-		logger.Fatalf("NewDefaultNode: %v", err)
 		return nil, err
 	}
 	_, port, err := net.SplitHostPort(listener.Addr().String())
 	if err != nil {
-		// Not reached
-		panic(err)
+		listener.Close()
+		return nil, err
 	}
 	node.xmlrpcURI = fmt.Sprintf("http://%s:%s", node.hostname, port)
 	logger.Debugf("listen on http://%s", listener.Addr().String())
@@ -210,6 +218,29 @@ func newDefaultNode(name string, args []string, opts ...NodeOption) (*defaultNod
 	}
 	node.xmlrpcHandler = xmlrpc.NewHandler(m)
 	go http.Serve(node.xmlrpcListener, node.xmlrpcHandler)
+
+	// Install signal handler, once nothing can fail anymore.
+	signal.Notify(node.interruptChan, os.Interrupt)
+	go func() {
+		<-node.interruptChan
+		logger.Info("Interrupted")
+		node.okMutex.Lock()
+		node.ok = false
+		node.okMutex.Unlock()
+	}()
+
+	node.startLogFile(logger)
+	node.startRosout(logger)
+	node.advertiseLoggerServices()
+
+	if useSimTime, err := node.GetParam("/use_sim_time"); err == nil && useSimTime == true {
+		logger.Debug("Use simulated time")
+		node.subscribeClock()
+	}
+	if node.masterCheckPeriod > 0 {
+		node.watchdogQuitChan = make(chan struct{})
+		go node.watchMaster(node.masterCheckPeriod, node.watchdogQuitChan)
+	}
 	logger.Debugf("Started %s", node.qualifiedName)
 	return node, nil
 }
@@ -222,11 +253,11 @@ func (node *defaultNode) OK() bool {
 }
 
 func (node *defaultNode) getBusStats(callerID string) (interface{}, error) {
-	return buildRosAPIResult(APIStatusError, "Not implemented", 0), nil
+	return buildRosAPIResult(APIStatusSuccess, "Success", node.busStats.stats()), nil
 }
 
 func (node *defaultNode) getBusInfo(callerID string) (interface{}, error) {
-	return buildRosAPIResult(APIStatusError, "Not implemeted", 0), nil
+	return buildRosAPIResult(APIStatusSuccess, "Success", node.busStats.info()), nil
 }
 
 func (node *defaultNode) getmasterURI(callerID string) (interface{}, error) {
@@ -268,15 +299,14 @@ func (node *defaultNode) getPublications(callerID string) (interface{}, error) {
 	return buildRosAPIResult(APIStatusSuccess, "Success", result), nil
 }
 
-func (node *defaultNode) paramUpdate(callerID string, key string, value interface{}) (interface{}, error) {
-	return buildRosAPIResult(APIStatusError, "Not implemented", 0), nil
-}
-
 func (node *defaultNode) publisherUpdate(callerID string, topic string, publishers []interface{}) (interface{}, error) {
 	node.logger.Debug("Slave API publisherUpdate() called.")
 	var code int32
 	var message string
-	if sub, ok := node.subscribers[topic]; !ok {
+	node.subscribersMutex.RLock()
+	sub, ok := node.subscribers[topic]
+	node.subscribersMutex.RUnlock()
+	if !ok {
 		node.logger.Debug("publisherUpdate() called without subscribing topic.")
 		code = APIStatusFailure
 		message = "No such topic"
@@ -303,63 +333,184 @@ func (node *defaultNode) requestTopic(callerID string, topic string, protocols [
 		return buildRosAPIResult(APIStatusFailure, "No such topic", nil), nil
 	}
 
-	selectedProtocol := make([]interface{}, 0)
+	// Pick the first supported protocol in the subscriber's order of preference.
+	var selectedProtocol []interface{}
 	for _, v := range protocols {
-		protocolParams := v.([]interface{})
-		protocolName := protocolParams[0].(string)
-		if protocolName == "TCPROS" {
+		protocolParams, ok := v.([]interface{})
+		if !ok || len(protocolParams) == 0 {
+			continue
+		}
+		protocolName, _ := protocolParams[0].(string)
+		if protocolName == TransportTCPROS {
 			node.logger.Debug("TCPROS requested")
-			selectedProtocol = append(selectedProtocol, "TCPROS")
 			host, portStr := pub.hostAndPort()
 			p, err := strconv.ParseInt(portStr, 10, 32)
 			if err != nil {
 				return nil, err
 			}
-			port := int(p)
-			selectedProtocol = append(selectedProtocol, host)
-			selectedProtocol = append(selectedProtocol, port)
+			selectedProtocol = []interface{}{TransportTCPROS, host, int(p)}
+			break
+		} else if protocolName == TransportUDPROS {
+			node.logger.Debug("UDPROS requested")
+			result, err := pub.acceptUDPROS(protocolParams)
+			if err != nil {
+				node.logger.Warnf("UDPROS rejected: %v", err)
+				continue
+			}
+			selectedProtocol = result
 			break
 		}
 	}
+	if selectedProtocol == nil {
+		return buildRosAPIResult(APIStatusFailure, "No supported protocol", nil), nil
+	}
 
 	node.logger.Debug(selectedProtocol)
 	return buildRosAPIResult(APIStatusSuccess, "Success", selectedProtocol), nil
 }
 
-func (node *defaultNode) NewPublisher(topic string, msgType MessageType) Publisher {
+// PublisherOption customizes publisher instances.
+type PublisherOption func(p *defaultPublisher)
+
+// PublisherLatched makes the publisher keep the last published message and send it
+// to every subscriber which connects later.
+func PublisherLatched() PublisherOption {
+	return func(p *defaultPublisher) {
+		p.latched = true
+	}
+}
+
+// PublisherQueueSize sets the number of outgoing messages queued for each subscriber (default 10).
+func PublisherQueueSize(size int) PublisherOption {
+	return func(p *defaultPublisher) {
+		if size < 1 {
+			size = 1
+		}
+		p.queueSize = size
+	}
+}
+
+// PublisherQueuePolicy chooses what happens when the outgoing queue is full (default QueueBlock).
+func PublisherQueuePolicy(policy QueuePolicy) PublisherOption {
+	return func(p *defaultPublisher) {
+		p.queuePolicy = policy
+	}
+}
+
+// publisherLogger replaces the node's logger for the publisher.
+func publisherLogger(logger Logger) PublisherOption {
+	return func(p *defaultPublisher) {
+		p.logger = logger
+	}
+}
+
+func (node *defaultNode) NewPublisher(topic string, msgType MessageType, options ...PublisherOption) Publisher {
+	name := node.nameResolver.remap(topic)
+	return node.NewPublisherWithCallbacks(name, msgType, nil, nil, options...)
+}
+
+func (node *defaultNode) NewPublisherE(topic string, msgType MessageType, options ...PublisherOption) (Publisher, error) {
 	name := node.nameResolver.remap(topic)
-	return node.NewPublisherWithCallbacks(name, msgType, nil, nil)
+	return node.NewPublisherWithCallbacksE(name, msgType, nil, nil, options...)
+}
+
+func (node *defaultNode) NewPublisherWithCallbacks(topic string, msgType MessageType, connectCallback, disconnectCallback func(SingleSubscriberPublisher), options ...PublisherOption) Publisher {
+	pub, err := node.NewPublisherWithCallbacksE(topic, msgType, connectCallback, disconnectCallback, options...)
+	if err != nil {
+		node.logger.Error(err)
+		return nil
+	}
+	return pub
 }
 
-func (node *defaultNode) NewPublisherWithCallbacks(topic string, msgType MessageType, connectCallback, disconnectCallback func(SingleSubscriberPublisher)) Publisher {
+func (node *defaultNode) NewPublisherWithCallbacksE(topic string, msgType MessageType, connectCallback, disconnectCallback func(SingleSubscriberPublisher), options ...PublisherOption) (Publisher, error) {
 	node.publishersMutex.Lock()
 	defer node.publishersMutex.Unlock()
 
 	name := node.nameResolver.remap(topic)
-	pub, ok := node.publishers[topic]
+	pub, ok := node.publishers[name]
 	if !ok {
-		_, err := callRosAPI(node.masterURI, "registerPublisher",
+		var err error
+		pub, err = newDefaultPublisher(node, name, msgType, connectCallback, disconnectCallback, options...)
+		if err != nil {
+			return nil, err
+		}
+		_, err = callRosAPI(node.masterURI, "registerPublisher",
 			node.qualifiedName,
 			name, msgType.Name(),
 			node.xmlrpcURI)
 		if err != nil {
-			node.logger.Fatalf("Failed to call registerPublisher(): %s", err)
+			pub.listener.Close()
+			return nil, fmt.Errorf("failed to register publisher of %s: %v", name, err)
 		}
 
-		pub = newDefaultPublisher(node, name, msgType, connectCallback, disconnectCallback)
 		node.publishers[name] = pub
+		node.waitGroup.Add(1)
 		go pub.start(&node.waitGroup)
 	}
 
-	return pub
+	return pub, nil
+}
+
+// SubscriberOption customizes subscriber instances.
+type SubscriberOption func(s *defaultSubscriber)
+
+// SubscriberQueueSize sets the number of incoming messages waiting for the callbacks (default 100).
+// Use 1 with QueueDropOldest to process only the latest message.
+func SubscriberQueueSize(size int) SubscriberOption {
+	return func(s *defaultSubscriber) {
+		if size < 1 {
+			size = 1
+		}
+		s.queueSize = size
+	}
 }
 
-func (node *defaultNode) NewSubscriber(topic string, msgType MessageType, callback interface{}) Subscriber {
+// SubscriberQueuePolicy chooses what happens when the incoming queue is full (default QueueBlock).
+func SubscriberQueuePolicy(policy QueuePolicy) SubscriberOption {
+	return func(s *defaultSubscriber) {
+		s.queuePolicy = policy
+	}
+}
+
+// SubscriberTransportHints lists the transports the subscriber accepts, in order of preference
+// (default TCPROS only). UDPROS drops messages which don't arrive complete.
+func SubscriberTransportHints(transports ...string) SubscriberOption {
+	return func(s *defaultSubscriber) {
+		s.transports = transports
+	}
+}
+
+// SubscriberMaxDatagramSize sets the largest UDPROS datagram the subscriber accepts (default 1500).
+func SubscriberMaxDatagramSize(size int) SubscriberOption {
+	return func(s *defaultSubscriber) {
+		s.maxDatagramSize = size
+	}
+}
+
+// SubscriberCallbackQueue makes the subscriber push its callbacks to queue instead of the
+// node's global callback queue, e.g. to serve them by a dedicated AsyncSpinner.
+func SubscriberCallbackQueue(queue *CallbackQueue) SubscriberOption {
+	return func(s *defaultSubscriber) {
+		s.callbackQueue = queue
+	}
+}
+
+func (node *defaultNode) NewSubscriber(topic string, msgType MessageType, callback interface{}, options ...SubscriberOption) Subscriber {
+	sub, err := node.NewSubscriberE(topic, msgType, callback, options...)
+	if err != nil {
+		node.logger.Error(err)
+		return nil
+	}
+	return sub
+}
+
+func (node *defaultNode) NewSubscriberE(topic string, msgType MessageType, callback interface{}, options ...SubscriberOption) (Subscriber, error) {
 	node.subscribersMutex.Lock()
 	defer node.subscribersMutex.Unlock()
 
 	name := node.nameResolver.remap(topic)
-	logger := node.logger
+	logger := node.logger.child("subscriber")
 
 	sub, ok := node.subscribers[name]
 	if !ok {
@@ -370,28 +521,36 @@ func (node *defaultNode) NewSubscriber(topic string, msgType MessageType, callba
 			msgType.Name(),
 			node.xmlrpcURI)
 		if err != nil {
-			logger.Fatalf("Failed to call registerSubscriber() for %s.", err)
+			return nil, fmt.Errorf("failed to register subscriber of %s: %v", name, err)
 		}
 		list, ok := result.([]interface{})
 		if !ok {
-			logger.Fatalf("result is not []string but %s.", reflect.TypeOf(result).String())
+			return nil, fmt.Errorf("result is not []string but %s", reflect.TypeOf(result).String())
 		}
 		var publishers []string
 		for _, item := range list {
 			s, ok := item.(string)
 			if !ok {
-				logger.Fatal("Publisher list contains no string object")
+				return nil, errors.New("publisher list contains no string object")
 			}
 			publishers = append(publishers, s)
 		}
 
 		logger.Debugf("Publisher URI list: %+v", publishers)
 
-		sub = newDefaultSubscriber(name, msgType, callback)
+		sub = newDefaultSubscriber(name, msgType, callback, options...)
+		sub.hostname = node.hostname
+		sub.listenIP = node.listenIP
+		sub.busStats = node.busStats
 		node.subscribers[name] = sub
 
 		logger.Debugf("Start subscriber goroutine for topic '%s'", sub.topic)
-		go sub.start(&node.waitGroup, node.qualifiedName, node.xmlrpcURI, node.masterURI, node.jobChan, logger, func() {
+		jobChan := node.jobChan
+		if sub.callbackQueue != nil {
+			jobChan = sub.callbackQueue.jobChan
+		}
+		node.waitGroup.Add(1)
+		go sub.start(&node.waitGroup, node.qualifiedName, node.xmlrpcURI, node.masterURI, jobChan, logger, func() {
 			node.subscribersMutex.Lock()
 			defer node.subscribersMutex.Unlock()
 			delete(node.subscribers, name)
@@ -403,7 +562,7 @@ func (node *defaultNode) NewSubscriber(topic string, msgType MessageType, callba
 		sub.callbacks = append(sub.callbacks, callback)
 	}
 
-	return sub
+	return sub, nil
 }
 
 // ServiceClientOption customizes service client instances.
@@ -418,13 +577,21 @@ func ServiceClientTCPTimeout(t time.Duration) ServiceClientOption {
 	}
 }
 
+// ServiceClientPersistent keeps the connection to the server open between calls. A persistent
+// client serializes its calls and reconnects when the connection fails.
+func ServiceClientPersistent() ServiceClientOption {
+	return func(c *defaultServiceClient) {
+		c.persistent = true
+	}
+}
+
 func (node *defaultNode) NewServiceClient(service string, srvType ServiceType, options ...ServiceClientOption) ServiceClient {
 	name := node.nameResolver.remap(service)
 	opts := []ServiceClientOption{}
 	opts = append(opts, node.srvClientOpts...)
 	opts = append(opts, options...)
 
-	client := newDefaultServiceClient(node.logger, node.qualifiedName, node.masterURI, name, srvType, opts...)
+	client := newDefaultServiceClient(node.logger.child("service"), node.qualifiedName, node.masterURI, name, srvType, opts...)
 	return client
 }
 
@@ -440,7 +607,38 @@ func ServiceServerTCPTimeout(t time.Duration) ServiceServerOption {
 	}
 }
 
+// ServiceServerConcurrent runs the handlers in their own goroutines instead of the node's
+// spinner, so requests are served without spinning and a slow request doesn't delay others.
+// At most workers handlers run at the same time, or any number if workers is not positive.
+func ServiceServerConcurrent(workers int) ServiceServerOption {
+	return func(s *defaultServiceServer) {
+		s.concurrent = true
+		if workers > 0 {
+			s.workers = make(chan struct{}, workers)
+		} else {
+			s.workers = nil
+		}
+	}
+}
+
+// ServiceServerHandlerTimeout changes the default 1s limit on the handler run time, after which the
+// client gets an error response. Zero disables the timeout.
+func ServiceServerHandlerTimeout(t time.Duration) ServiceServerOption {
+	return func(s *defaultServiceServer) {
+		s.handlerTimeout = t
+	}
+}
+
 func (node *defaultNode) NewServiceServer(service string, srvType ServiceType, handler interface{}, options ...ServiceServerOption) ServiceServer {
+	server, err := node.NewServiceServerE(service, srvType, handler, options...)
+	if err != nil {
+		node.logger.Error(err)
+		return nil
+	}
+	return server
+}
+
+func (node *defaultNode) NewServiceServerE(service string, srvType ServiceType, handler interface{}, options ...ServiceServerOption) (ServiceServer, error) {
 	node.serversMutex.Lock()
 	defer node.serversMutex.Unlock()
 
@@ -454,13 +652,13 @@ func (node *defaultNode) NewServiceServer(service string, srvType ServiceType, h
 	opts = append(opts, node.srvServerOpts...)
 	opts = append(opts, options...)
 
-	server = newDefaultServiceServer(node, name, srvType, handler, opts...)
-	if server == nil {
-		return nil
+	server, err := newDefaultServiceServer(node, name, srvType, handler, opts...)
+	if err != nil {
+		return nil, err
 	}
 
 	node.servers[name] = server
-	return server
+	return server, nil
 }
 
 func (node *defaultNode) SpinOnce() {
@@ -492,6 +690,29 @@ func (node *defaultNode) Shutdown() {
 	node.okMutex.Lock()
 	node.ok = false
 	node.okMutex.Unlock()
+	if node.watchdogQuitChan != nil {
+		close(node.watchdogQuitChan)
+	}
+	// Stopping removes them from the node, so they are collected first.
+	var stoppers []interface{ Stop() }
+	node.spinnersMutex.Lock()
+	for t := range node.timers {
+		stoppers = append(stoppers, t)
+	}
+	for s := range node.spinners {
+		stoppers = append(stoppers, s)
+	}
+	for w := range node.graphWatchers {
+		stoppers = append(stoppers, w)
+	}
+	node.spinnersMutex.Unlock()
+	for _, s := range stoppers {
+		s.Stop()
+	}
+	disableSimTime(node)
+	if node.rosout != nil {
+		node.rosout.close()
+	}
 	node.logger.Debug("Shutdown subscribers")
 	for _, s := range node.subscribers {
 		s.Shutdown()
@@ -507,6 +728,11 @@ func (node *defaultNode) Shutdown() {
 		s.Shutdown()
 	}
 	node.logger.Debug("Shutdown servers...done")
+	for _, name := range node.params.names() {
+		if err := node.unsubscribeParam(name); err != nil {
+			node.logger.Warnf("Failed to unsubscribe parameter %s: %v", name, err)
+		}
+	}
 	node.logger.Debug("Wait all goroutines")
 	node.waitGroup.Wait()
 	node.logger.Debug("Wait all goroutines...Done")
@@ -517,6 +743,9 @@ func (node *defaultNode) Shutdown() {
 	node.xmlrpcHandler.WaitForShutdown()
 	node.logger.Debug("Wait XMLRPC server shutdown...Done")
 	node.logger.Debug("Shutting node down completed")
+	if node.logFile != nil {
+		node.logFile.Close()
+	}
 	return
 }
 
@@ -568,12 +797,27 @@ func (node *defaultNode) Name() string {
 	return node.name
 }
 
-func loadParamFromString(s string) (interface{}, error) {
-	decoder := json.NewDecoder(strings.NewReader(s))
-	var value interface{}
-	err := decoder.Decode(&value)
+// setPrivateParams sets the private parameters given by _param:=value arguments.
+func (node *defaultNode) setPrivateParams() error {
+	for k, v := range node.privateParams {
+		_, err := callRosAPI(node.masterURI, "setParam", node.qualifiedName, node.nameResolver.resolve("~"+k), loadParamFromString(v))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadParamFromString parses the value of a _param:=value argument as YAML like rospy, values
+// which aren't valid YAML parameters are kept as strings.
+func loadParamFromString(s string) interface{} {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(s), &doc); err != nil || len(doc.Content) == 0 || doc.Content[0].Tag == "!!null" {
+		return s
+	}
+	value, err := decodeParamYAML(&doc)
 	if err != nil {
-		return nil, err
+		return s
 	}
-	return value, err
+	return value
 }
@@ -1,19 +1,82 @@
 package ros
 
 import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 )
 
-func TestLoadJsonFromString(t *testing.T) {
-	value, err := loadParamFromString("42")
+func TestLoadParamFromString(t *testing.T) {
+	var tests = []struct {
+		s        string
+		expected interface{}
+	}{
+		{"42", int32(42)},
+		{"-1.5", -1.5},
+		{"true", true},
+		{"hello", "hello"},
+		{"'42'", "42"},
+		{"", ""},
+		{"[1, two]", []interface{}{int32(1), "two"}},
+		{"{a: 1, b: {c: x}}", map[string]interface{}{"a": int32(1), "b": map[string]interface{}{"c": "x"}}},
+		{"!degrees 180", math.Pi},
+		{"10000000000", "10000000000"},
+		{"[unclosed", "[unclosed"},
+	}
+	for _, test := range tests {
+		if value := loadParamFromString(test.s); !reflect.DeepEqual(value, test.expected) {
+			t.Errorf("%q: %#v", test.s, value)
+		}
+	}
+}
+
+func TestPrivateParamArguments(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	logDir := filepath.Join(os.TempDir(), "rosgo_test_log")
+	node, err := newDefaultNode("/args_test", []string{"__master:=" + m.URI(), "__ip:=127.0.0.1", "__log:=" + logDir,
+		"__ns:=/robot", "_rate:=10", "_frame:=map", "_gains:={p: 1.5}"})
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
+	}
+	defer node.Shutdown()
+	if rate, err := node.GetParam("/robot/args_test/rate"); err != nil || rate != int32(10) {
+		t.Error(rate, err)
+	}
+	if frame, err := node.GetParam("~frame"); err != nil || frame != "map" {
+		t.Error(frame, err)
+	}
+	if p, err := node.GetParamFloat64("~gains/p"); err != nil || p != 1.5 {
+		t.Error(p, err)
+	}
+}
+
+func TestConstructorErrors(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	node := newTestNode(t, m, "/error_test")
+	defer node.Shutdown()
+	// Nothing listens on the port 1.
+	node.masterURI = "http://127.0.0.1:1"
+
+	if pub, err := node.NewPublisherE("/chatter", msgTestMessage); err == nil || pub != nil {
+		t.Error("publisher must fail without master", pub, err)
+	}
+	if sub, err := node.NewSubscriberE("/chatter", msgTestMessage, func(*testMessage) {}); err == nil || sub != nil {
+		t.Error("subscriber must fail without master", sub, err)
+	}
+	if server, err := node.NewServiceServerE("/echo", srvTestService, echoHandler); err == nil || server != nil {
+		t.Error("service server must fail without master", server, err)
+	}
+	if server := node.NewServiceServer("/echo", srvTestService, echoHandler); server != nil {
+		t.Error(server)
 	}
-	i, ok := value.(float64)
-	if !ok {
-		t.Fail()
+	if pub := node.NewPublisher("/chatter", msgTestMessage); pub != nil {
+		t.Error(pub)
 	}
-	if i != 42.0 {
-		t.Error(i)
+	if sub := node.NewSubscriber("/chatter", msgTestMessage, func(*testMessage) {}); sub != nil {
+		t.Error(sub)
 	}
 }
@@ -0,0 +1,222 @@
+package ros
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ParamError is the error of a parameter which doesn't fit the Go value it's read into.
+type ParamError struct {
+	Name string // Resolved name of the parameter.
+	Err  error
+}
+
+func (e *ParamError) Error() string {
+	return e.Name + ": " + e.Err.Error()
+}
+
+// ParamErrors are the errors of all the parameters BindParams couldn't read.
+type ParamErrors []*ParamError
+
+func (e ParamErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// GetParamInt reads an integer parameter.
+func (node *defaultNode) GetParamInt(name string) (int, error) {
+	var value int
+	err := node.getParamInto(name, &value)
+	return value, err
+}
+
+// GetParamFloat64 reads a float parameter, integers are converted.
+func (node *defaultNode) GetParamFloat64(name string) (float64, error) {
+	var value float64
+	err := node.getParamInto(name, &value)
+	return value, err
+}
+
+// GetParamBool reads a boolean parameter.
+func (node *defaultNode) GetParamBool(name string) (bool, error) {
+	var value bool
+	err := node.getParamInto(name, &value)
+	return value, err
+}
+
+// GetParamString reads a string parameter.
+func (node *defaultNode) GetParamString(name string) (string, error) {
+	var value string
+	err := node.getParamInto(name, &value)
+	return value, err
+}
+
+// GetParamStringSlice reads a list of strings.
+func (node *defaultNode) GetParamStringSlice(name string) ([]string, error) {
+	var value []string
+	err := node.getParamInto(name, &value)
+	return value, err
+}
+
+// GetParamOr reads a parameter of the type of defaultValue, like GetParamInt if defaultValue is
+// an int. It returns defaultValue if the parameter isn't set or doesn't fit the type.
+func (node *defaultNode) GetParamOr(name string, defaultValue interface{}) interface{} {
+	if defaultValue == nil {
+		if value, err := node.GetParam(name); err == nil {
+			return value
+		}
+		return defaultValue
+	}
+	value := reflect.New(reflect.TypeOf(defaultValue))
+	if err := node.getParamInto(name, value.Interface()); err != nil {
+		return defaultValue
+	}
+	return value.Elem().Interface()
+}
+
+// BindParams fills the struct pointed by v with the parameters in namespace. The fields are
+// bound to the parameters named by their rosparam tags, nested structs to dictionaries. Fields
+// without parameter are left unchanged. The errors of every field are returned in ParamErrors.
+func (node *defaultNode) BindParams(namespace string, v interface{}) error {
+	ptr := reflect.ValueOf(v)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() || ptr.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("BindParams needs a pointer to a struct, not a %T", v)
+	}
+	return node.getParamInto(namespace, v)
+}
+
+func (node *defaultNode) getParamInto(name string, v interface{}) error {
+	value, err := node.GetParam(name)
+	if err != nil {
+		return err
+	}
+	var errs ParamErrors
+	bindParam(reflect.ValueOf(v).Elem(), value, node.nameResolver.remap(name), &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// bindParam sets v from the parameter value. The errors are added to errs, so that all the
+// values which fit are set.
+func bindParam(v reflect.Value, value interface{}, name string, errs *ParamErrors) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, &ParamError{name, fmt.Errorf(format, args...)})
+	}
+	mismatch := func() {
+		fail("expected %s, got %T %v", v.Type(), value, value)
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		if value == nil {
+			v.Set(reflect.Zero(v.Type()))
+		} else if reflect.TypeOf(value).AssignableTo(v.Type()) {
+			v.Set(reflect.ValueOf(value))
+		} else {
+			mismatch()
+		}
+	case reflect.Bool:
+		b, ok := value.(bool)
+		if !ok {
+			mismatch()
+			return
+		}
+		v.SetBool(b)
+	case reflect.String:
+		s, ok := value.(string)
+		if !ok {
+			mismatch()
+			return
+		}
+		v.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := paramInt(value)
+		if !ok || v.OverflowInt(i) {
+			mismatch()
+			return
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, ok := paramInt(value)
+		if !ok || i < 0 || v.OverflowUint(uint64(i)) {
+			mismatch()
+			return
+		}
+		v.SetUint(uint64(i))
+	case reflect.Float32, reflect.Float64:
+		f, ok := value.(float64)
+		if i, isInt := paramInt(value); isInt {
+			f, ok = float64(i), true
+		}
+		if !ok {
+			mismatch()
+			return
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		list, ok := value.([]interface{})
+		if !ok {
+			mismatch()
+			return
+		}
+		slice := reflect.MakeSlice(v.Type(), len(list), len(list))
+		for i, item := range list {
+			bindParam(slice.Index(i), item, fmt.Sprintf("%s[%d]", name, i), errs)
+		}
+		v.Set(slice)
+	case reflect.Map:
+		dict, ok := value.(map[string]interface{})
+		if !ok || v.Type().Key().Kind() != reflect.String {
+			mismatch()
+			return
+		}
+		m := reflect.MakeMap(v.Type())
+		for key, item := range dict {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			bindParam(elem, item, paramChild(name, key), errs)
+			m.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+		}
+		v.Set(m)
+	case reflect.Struct:
+		dict, ok := value.(map[string]interface{})
+		if !ok {
+			mismatch()
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			tag := field.Tag.Get("rosparam")
+			if len(tag) == 0 || tag == "-" || len(field.PkgPath) > 0 {
+				continue
+			}
+			if item, ok := dict[tag]; ok {
+				bindParam(v.Field(i), item, paramChild(name, tag), errs)
+			}
+		}
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		bindParam(v.Elem(), value, name, errs)
+	default:
+		fail("can't read a parameter into a %s", v.Type())
+	}
+}
+
+// paramInt returns the value of an integer parameter, which is an int32 from XML-RPC.
+func paramInt(value interface{}) (int64, bool) {
+	switch i := value.(type) {
+	case int32:
+		return int64(i), true
+	case int:
+		return int64(i), true
+	case int64:
+		return i, true
+	}
+	return 0, false
+}
@@ -0,0 +1,110 @@
+package ros
+
+import (
+	"reflect"
+	"testing"
+)
+
+type bindTestLimits struct {
+	MaxSpeed float64 `rosparam:"max_speed"`
+	MaxTurn  float32 `rosparam:"max_turn"`
+}
+
+type bindTestConfig struct {
+	Rate     int             `rosparam:"rate"`
+	Retries  uint8           `rosparam:"retries"`
+	Frame    string          `rosparam:"frame"`
+	Enabled  bool            `rosparam:"enabled"`
+	Joints   []string        `rosparam:"joints"`
+	Limits   bindTestLimits  `rosparam:"limits"`
+	Gains    map[string]int  `rosparam:"gains"`
+	Extra    interface{}     `rosparam:"extra"`
+	Optional *bindTestLimits `rosparam:"optional"`
+	Default  string          `rosparam:"default"`
+	Ignored  int             `rosparam:"-"`
+	Untagged int
+}
+
+func TestBindParam(t *testing.T) {
+	params := map[string]interface{}{
+		"rate":     int32(10),
+		"retries":  int32(300),
+		"frame":    "map",
+		"enabled":  "yes",
+		"joints":   []interface{}{"shoulder", int32(2), "wrist"},
+		"limits":   map[string]interface{}{"max_speed": int32(2), "max_turn": 0.5},
+		"gains":    map[string]interface{}{"p": int32(1), "d": 0.1},
+		"extra":    []interface{}{true},
+		"optional": map[string]interface{}{"max_speed": 1.5},
+		"Ignored":  int32(1),
+		"Untagged": int32(1),
+	}
+	config := bindTestConfig{Default: "kept", Retries: 3, Enabled: true}
+	var errs ParamErrors
+	bindParam(reflect.ValueOf(&config).Elem(), params, "/robot", &errs)
+
+	expected := bindTestConfig{
+		Rate:     10,
+		Retries:  3,
+		Frame:    "map",
+		Enabled:  true,
+		Joints:   []string{"shoulder", "", "wrist"},
+		Limits:   bindTestLimits{2, 0.5},
+		Gains:    map[string]int{"p": 1, "d": 0},
+		Extra:    []interface{}{true},
+		Optional: &bindTestLimits{MaxSpeed: 1.5},
+		Default:  "kept",
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("%+v", config)
+	}
+	names := make(map[string]bool)
+	for _, err := range errs {
+		names[err.Name] = true
+	}
+	if len(errs) != 4 || !names["/robot/retries"] || !names["/robot/enabled"] || !names["/robot/joints[1]"] || !names["/robot/gains/d"] {
+		t.Error(errs)
+	}
+}
+
+func TestTypedParams(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	node := newTestNode(t, m, "/param_bind_test")
+	defer node.Shutdown()
+
+	node.SetParam("~rate", 10)
+	node.SetParam("~scale", 0.5)
+	node.SetParam("~joints", []string{"shoulder", "elbow"})
+	node.SetParam("~limits", map[string]interface{}{"max_speed": 2.5, "max_turn": "fast"})
+
+	if rate, err := node.GetParamInt("~rate"); err != nil || rate != 10 {
+		t.Error(rate, err)
+	}
+	if rate, err := node.GetParamFloat64("~rate"); err != nil || rate != 10 {
+		t.Error(rate, err)
+	}
+	if _, err := node.GetParamInt("~scale"); err == nil || err.Error() != "/param_bind_test/scale: expected int, got float64 0.5" {
+		t.Error(err)
+	}
+	if joints, err := node.GetParamStringSlice("~joints"); err != nil || !reflect.DeepEqual(joints, []string{"shoulder", "elbow"}) {
+		t.Error(joints, err)
+	}
+	if _, err := node.GetParamString("~missing"); err == nil {
+		t.Error("missing parameters must fail")
+	}
+	if node.GetParamOr("~rate", 1) != 10 || node.GetParamOr("~missing", 1) != 1 || node.GetParamOr("~scale", 1) != 1 ||
+		node.GetParamOr("~scale", float32(1)) != float32(0.5) {
+		t.Error("GetParamOr")
+	}
+
+	var limits bindTestLimits
+	err := node.BindParams("~limits", &limits)
+	errs, ok := err.(ParamErrors)
+	if !ok || len(errs) != 1 || errs[0].Name != "/param_bind_test/limits/max_turn" || limits.MaxSpeed != 2.5 {
+		t.Error(limits, err)
+	}
+	if err := node.BindParams("~limits", limits); err == nil {
+		t.Error("BindParams needs a pointer")
+	}
+}
@@ -0,0 +1,257 @@
+package ros
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ParamCallback is called with the name and new value of a subscribed parameter, or of one of
+// its descendants, when the parameter server notifies a change. value is nil for deleted parameters.
+type ParamCallback func(name string, value interface{})
+
+type paramSubscription struct {
+	value        interface{}
+	cached       bool
+	callbacks    []ParamCallback
+	pending      bool // A callback job is queued, later changes are passed to it.
+	changed      string
+	changedValue interface{}
+}
+
+// paramCache keeps the values of the parameters the node subscribed to. The cache is updated
+// by the paramUpdate slave API.
+type paramCache struct {
+	mutex         sync.Mutex
+	subscriptions map[string]*paramSubscription
+	generation    int // Incremented by each update, so that stale values from the master are not cached.
+}
+
+func newParamCache() *paramCache {
+	c := new(paramCache)
+	c.subscriptions = make(map[string]*paramSubscription)
+	return c
+}
+
+// isParamDescendant returns true if child is a parameter inside the dictionary parent.
+func isParamDescendant(parent string, child string) bool {
+	return parent == GlobalNS && child != GlobalNS || strings.HasPrefix(child, parent+Sep)
+}
+
+// paramChild returns the name of the parameter key in the dictionary name.
+func paramChild(name string, key string) string {
+	if name == GlobalNS {
+		return GlobalNS + key
+	}
+	return name + Sep + key
+}
+
+// paramValue converts an empty dictionary, which the master uses for unset parameters, to nil.
+func paramValue(value interface{}) interface{} {
+	if dict, ok := value.(map[string]interface{}); ok && len(dict) == 0 {
+		return nil
+	}
+	return value
+}
+
+// subscribed returns true if the node already subscribed to the parameter.
+func (c *paramCache) subscribed(name string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	_, ok := c.subscriptions[name]
+	return ok
+}
+
+// add registers a subscription before calling the master's subscribeParam, so that no
+// update is missed. The returned generation is passed to store with the value from the master.
+func (c *paramCache) add(name string) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.subscriptions[name]; !ok {
+		c.subscriptions[name] = new(paramSubscription)
+	}
+	return c.generation
+}
+
+func (c *paramCache) addCallback(name string, callback ParamCallback) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if s, ok := c.subscriptions[name]; ok {
+		s.callbacks = append(s.callbacks, callback)
+	}
+}
+
+func (c *paramCache) remove(name string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.subscriptions, name)
+}
+
+func (c *paramCache) names() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var names []string
+	for name := range c.subscriptions {
+		names = append(names, name)
+	}
+	return names
+}
+
+// get returns the cached value and whether it is valid. The generation is passed to store
+// after fetching an invalid value from the master.
+func (c *paramCache) get(name string) (value interface{}, ok bool, generation int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	s, subscribed := c.subscriptions[name]
+	if !subscribed || !s.cached {
+		return nil, false, c.generation
+	}
+	return s.value, true, c.generation
+}
+
+// store caches a value fetched from the master unless the parameter changed in between.
+func (c *paramCache) store(name string, value interface{}, generation int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if s, ok := c.subscriptions[name]; ok && generation == c.generation {
+		s.value = paramValue(value)
+		s.cached = true
+	}
+}
+
+// update applies a paramUpdate and returns the callback jobs to queue. A subscribed dictionary
+// whose member changed is dropped from the cache and fetched again on the next read. Each
+// subscription has at most one job queued, which passes the latest change to the callbacks.
+func (c *paramCache) update(name string, value interface{}) []func() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.generation++
+	value = paramValue(value)
+	var jobs []func()
+	for key, s := range c.subscriptions {
+		if key == name {
+			s.value = value
+			s.cached = true
+		} else if isParamDescendant(key, name) {
+			s.value = nil
+			s.cached = false
+		} else {
+			continue
+		}
+		if len(s.callbacks) == 0 {
+			continue
+		}
+		s.changed = name
+		s.changedValue = value
+		if !s.pending {
+			s.pending = true
+			jobs = append(jobs, c.callbackJob(key, s))
+		}
+	}
+	return jobs
+}
+
+// callbackJob returns a job which calls the callbacks of a subscription with its latest change.
+func (c *paramCache) callbackJob(key string, s *paramSubscription) func() {
+	return func() {
+		c.mutex.Lock()
+		s.pending = false
+		name, value := s.changed, s.changedValue
+		var callbacks []ParamCallback
+		if c.subscriptions[key] == s {
+			callbacks = append(callbacks, s.callbacks...)
+		}
+		c.mutex.Unlock()
+		for _, callback := range callbacks {
+			callback(name, value)
+		}
+	}
+}
+
+func (node *defaultNode) paramUpdate(callerID string, key string, value interface{}) (interface{}, error) {
+	node.logger.Debugf("Slave API paramUpdate(%s, %s, ...) called.", callerID, key)
+	node.queueParamCallbacks(node.params.update(canonicalizeName(key), value))
+	return buildRosAPIResult(APIStatusSuccess, "Success", 0), nil
+}
+
+// queueParamCallbacks queues the callback jobs of a parameter change without waiting for the
+// node to spin. When the queue is full, a job is queued in the background. Changes made in
+// the meantime are passed to the job, so there is at most one such goroutine per subscription.
+func (node *defaultNode) queueParamCallbacks(jobs []func()) {
+	for _, job := range jobs {
+		select {
+		case node.jobChan <- job:
+		default:
+			go func(job func()) {
+				for node.OK() {
+					select {
+					case node.jobChan <- job:
+						return
+					case <-time.After(100 * time.Millisecond):
+					}
+				}
+			}(job)
+		}
+	}
+}
+
+// SubscribeParam subscribes to the changes of a parameter. Reads by GetParamCached are then
+// served from the node, and callback, if not nil, is called from Spin on every change.
+func (node *defaultNode) SubscribeParam(key string, callback ParamCallback) error {
+	return node.subscribeParam(node.nameResolver.remap(key), callback)
+}
+
+func (node *defaultNode) subscribeParam(name string, callback ParamCallback) error {
+	if !node.params.subscribed(name) {
+		generation := node.params.add(name)
+		value, err := callRosAPI(node.masterURI, "subscribeParam", node.qualifiedName, node.xmlrpcURI, name)
+		if err != nil {
+			node.params.remove(name)
+			return err
+		}
+		node.params.store(name, value, generation)
+	}
+	if callback != nil {
+		node.params.addCallback(name, callback)
+	}
+	return nil
+}
+
+// UnsubscribeParam stops the updates of a parameter and drops its callbacks.
+func (node *defaultNode) UnsubscribeParam(key string) error {
+	return node.unsubscribeParam(node.nameResolver.remap(key))
+}
+
+func (node *defaultNode) unsubscribeParam(name string) error {
+	if !node.params.subscribed(name) {
+		return nil
+	}
+	node.params.remove(name)
+	_, err := callRosAPI(node.masterURI, "unsubscribeParam", node.qualifiedName, node.xmlrpcURI, name)
+	return err
+}
+
+// GetParamCached returns the value of a parameter from the node cache, subscribing to the
+// parameter on the first call.
+func (node *defaultNode) GetParamCached(key string) (interface{}, error) {
+	name := node.nameResolver.remap(key)
+	if !node.params.subscribed(name) {
+		if err := node.subscribeParam(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	value, ok, generation := node.params.get(name)
+	if !ok {
+		var err error
+		if value, err = callRosAPI(node.masterURI, "getParam", node.qualifiedName, name); err != nil {
+			return nil, err
+		}
+		node.params.store(name, value, generation)
+		value = paramValue(value)
+	}
+	if value == nil {
+		return nil, fmt.Errorf("parameter %s is not set", name)
+	}
+	return value, nil
+}
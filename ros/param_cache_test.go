@@ -0,0 +1,127 @@
+package ros
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParamCacheUpdate(t *testing.T) {
+	c := newParamCache()
+	c.store("/a", 1, c.add("/a"))
+	c.store("/b", map[string]interface{}{"c": 2}, c.add("/b"))
+	var calls []string
+	c.addCallback("/b", func(name string, value interface{}) {
+		calls = append(calls, name)
+	})
+
+	for _, cb := range c.update("/b/c", 3) {
+		cb()
+	}
+	if !reflect.DeepEqual(calls, []string{"/b/c"}) {
+		t.Error(calls)
+	}
+	if _, ok, _ := c.get("/b"); ok {
+		t.Error("dictionary must be invalidated when a member changes")
+	}
+	if v, ok, _ := c.get("/a"); !ok || v != 1 {
+		t.Error(v, ok)
+	}
+
+	// A value fetched before an update must not be cached.
+	_, _, generation := c.get("/b")
+	c.update("/a", map[string]interface{}{})
+	c.store("/b", "stale", generation)
+	if _, ok, _ := c.get("/b"); ok {
+		t.Error("stale value was cached")
+	}
+	if v, ok, _ := c.get("/a"); !ok || v != nil {
+		t.Error("deleted parameter must be cached as nil", v, ok)
+	}
+}
+
+func TestGetParamCached(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	node := newTestNode(t, m, "/param_test")
+	defer node.Shutdown()
+
+	if err := node.SetParam("/gain", 1.5); err != nil {
+		t.Fatal(err)
+	}
+	var changes []interface{}
+	if err := node.SubscribeParam("/gain", func(name string, value interface{}) {
+		changes = append(changes, value)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := node.GetParamCached("/gain"); err != nil || v != 1.5 {
+		t.Error(v, err)
+	}
+
+	node.SetParam("/gain", 2.5)
+	if !spinUntil(node, 5*time.Second, func() bool { return len(changes) > 0 }) {
+		t.Fatal("parameter change was not notified")
+	}
+	if changes[0] != 2.5 {
+		t.Error(changes)
+	}
+	if v, err := node.GetParamCached("/gain"); err != nil || v != 2.5 {
+		t.Error(v, err)
+	}
+
+	node.DeleteParam("/gain")
+	if !spinUntil(node, 5*time.Second, func() bool { return len(changes) > 1 }) {
+		t.Fatal("parameter deletion was not notified")
+	}
+	if changes[1] != nil {
+		t.Error(changes)
+	}
+	if _, err := node.GetParamCached("/gain"); err == nil {
+		t.Error("deleted parameter must not be returned")
+	}
+}
+
+func TestParamUpdateWithoutSpin(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	node := newTestNode(t, m, "/param_nospin_test")
+	defer node.Shutdown()
+
+	var values []interface{}
+	if err := node.SubscribeParam("/rate", func(name string, value interface{}) {
+		values = append(values, value)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// The node doesn't spin and the callback queue is full.
+	for i := 0; i < cap(node.jobChan); i++ {
+		node.jobChan <- func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 2*cap(node.jobChan); i++ {
+			node.paramUpdate("/master", "/rate", int32(i))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("paramUpdate blocked")
+	}
+	last := int32(2*cap(node.jobChan) - 1)
+	if v, err := node.GetParamCached("/rate"); err != nil || v != last {
+		t.Error(v, err)
+	}
+	// The changes are coalesced, the callback gets the latest value once the node spins.
+	if !spinUntil(node, 5*time.Second, func() bool { return len(values) > 0 }) {
+		t.Fatal("the parameter change was lost")
+	}
+	for i := 0; i < 10; i++ {
+		node.SpinOnce()
+	}
+	if !reflect.DeepEqual(values, []interface{}{last}) {
+		t.Error(values)
+	}
+}
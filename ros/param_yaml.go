@@ -0,0 +1,292 @@
+package ros
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadParamsFromYAML sets the parameters of a YAML file in namespace, like rosparam load.
+// Dictionaries update the existing ones instead of replacing them, and the angles tagged with
+// !degrees or !radians are converted to radians.
+func (node *defaultNode) LoadParamsFromYAML(file string, namespace string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	ns := node.nameResolver.remap(namespace)
+	decoder := yaml.NewDecoder(f)
+	for {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+		if len(doc.Content) == 0 || doc.Content[0].Tag == "!!null" {
+			continue
+		}
+		value, err := decodeParamYAML(&doc)
+		if err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+		if err := node.setParamTree(ns, value); err != nil {
+			return err
+		}
+	}
+}
+
+// DumpParams returns the parameters in namespace as YAML, like rosparam dump.
+func (node *defaultNode) DumpParams(namespace string) ([]byte, error) {
+	value, err := node.GetParam(namespace)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(paramToYAML(value))
+}
+
+// paramToYAML wraps the floats of a parameter value, so that integral floats are dumped as
+// floats and loaded back as floats.
+func paramToYAML(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		dict := make(map[string]interface{})
+		for key, item := range v {
+			dict[key] = paramToYAML(item)
+		}
+		return dict
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = paramToYAML(item)
+		}
+		return list
+	case float64:
+		return yamlFloat(v)
+	default:
+		return value
+	}
+}
+
+type yamlFloat float64
+
+func (f yamlFloat) MarshalYAML() (interface{}, error) {
+	text := strconv.FormatFloat(float64(f), 'g', -1, 64)
+	switch {
+	case math.IsNaN(float64(f)):
+		text = ".nan"
+	case math.IsInf(float64(f), 1):
+		text = ".inf"
+	case math.IsInf(float64(f), -1):
+		text = "-.inf"
+	case !strings.ContainsAny(text, ".e"):
+		text += ".0"
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: text}, nil
+}
+
+// setParamTree sets the leaves of the dictionary value one by one, so that the other
+// parameters of the dictionaries are kept. Like rosparam, an empty dictionary sets nothing.
+func (node *defaultNode) setParamTree(name string, value interface{}) error {
+	dict, ok := value.(map[string]interface{})
+	if !ok {
+		if name == GlobalNS {
+			return fmt.Errorf("the root of the parameter tree must be a dictionary")
+		}
+		_, err := callRosAPI(node.masterURI, "setParam", node.qualifiedName, name, value)
+		return err
+	}
+	keys := make([]string, 0, len(dict))
+	for key := range dict {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := node.setParamTree(paramChild(name, key), dict[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeParamYAML decodes a YAML node into a parameter value which XML-RPC can carry.
+func decodeParamYAML(node *yaml.Node) (interface{}, error) {
+	if err := convertAngles(node); err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return nil, err
+	}
+	return paramFromYAML(value, "")
+}
+
+// convertAngles replaces the scalars tagged with !degrees or !radians by their value in radians.
+// Radians can be expressions of pi, like "pi/2".
+func convertAngles(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode && (node.Tag == "!degrees" || node.Tag == "!radians") {
+		var angle float64
+		var err error
+		if node.Tag == "!degrees" {
+			angle, err = strconv.ParseFloat(strings.TrimSpace(node.Value), 64)
+			angle = angle * math.Pi / 180
+		} else {
+			angle, err = evalRadians(node.Value)
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: invalid %s value %q", node.Line, node.Tag, node.Value)
+		}
+		node.Tag = "!!float"
+		node.Value = strconv.FormatFloat(angle, 'g', -1, 64)
+		return nil
+	}
+	for _, child := range node.Content {
+		if err := convertAngles(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// paramFromYAML converts a decoded YAML value to the types of XML-RPC, path is the name of the
+// value in errors.
+func paramFromYAML(value interface{}, path string) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		dict := make(map[string]interface{})
+		for key, item := range v {
+			converted, err := paramFromYAML(item, path+Sep+key)
+			if err != nil {
+				return nil, err
+			}
+			dict[key] = converted
+		}
+		return dict, nil
+	case map[interface{}]interface{}:
+		dict := make(map[string]interface{})
+		for key, item := range v {
+			name := fmt.Sprint(key)
+			converted, err := paramFromYAML(item, path+Sep+name)
+			if err != nil {
+				return nil, err
+			}
+			dict[name] = converted
+		}
+		return dict, nil
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			converted, err := paramFromYAML(item, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			list[i] = converted
+		}
+		return list, nil
+	case int:
+		if v < math.MinInt32 || v > math.MaxInt32 {
+			return nil, fmt.Errorf("%s: %d overflows the 32 bits integers of XML-RPC", paramPath(path), v)
+		}
+		return int32(v), nil
+	case int64, uint64:
+		return nil, fmt.Errorf("%s: %d overflows the 32 bits integers of XML-RPC", paramPath(path), v)
+	case float64, bool, string:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("%s: unsupported parameter value %v", paramPath(path), value)
+	}
+}
+
+func paramPath(path string) string {
+	if len(path) == 0 {
+		return "parameter"
+	}
+	return strings.TrimPrefix(path, Sep)
+}
+
+// evalRadians evaluates an arithmetic expression of numbers and pi, with +, -, *, / and
+// parentheses.
+func evalRadians(expr string) (float64, error) {
+	p := &radiansParser{expr: strings.Replace(expr, " ", "", -1)}
+	value, err := p.sum()
+	if err == nil && p.pos < len(p.expr) {
+		err = fmt.Errorf("unexpected %q", p.expr[p.pos:])
+	}
+	return value, err
+}
+
+type radiansParser struct {
+	expr string
+	pos  int
+}
+
+func (p *radiansParser) peek() byte {
+	if p.pos < len(p.expr) {
+		return p.expr[p.pos]
+	}
+	return 0
+}
+
+func (p *radiansParser) sum() (float64, error) {
+	value, err := p.product()
+	for err == nil && (p.peek() == '+' || p.peek() == '-') {
+		op := p.peek()
+		p.pos++
+		var rhs float64
+		rhs, err = p.product()
+		if op == '+' {
+			value += rhs
+		} else {
+			value -= rhs
+		}
+	}
+	return value, err
+}
+
+func (p *radiansParser) product() (float64, error) {
+	value, err := p.factor()
+	for err == nil && (p.peek() == '*' || p.peek() == '/') {
+		op := p.peek()
+		p.pos++
+		var rhs float64
+		rhs, err = p.factor()
+		if op == '*' {
+			value *= rhs
+		} else {
+			value /= rhs
+		}
+	}
+	return value, err
+}
+
+func (p *radiansParser) factor() (float64, error) {
+	switch {
+	case p.peek() == '-':
+		p.pos++
+		value, err := p.factor()
+		return -value, err
+	case p.peek() == '(':
+		p.pos++
+		value, err := p.sum()
+		if err == nil && p.peek() != ')' {
+			err = fmt.Errorf("missing ')'")
+		}
+		p.pos++
+		return value, err
+	case strings.HasPrefix(p.expr[p.pos:], "pi"):
+		p.pos += 2
+		return math.Pi, nil
+	}
+	start := p.pos
+	for p.pos < len(p.expr) && strings.IndexByte("0123456789.", p.expr[p.pos]) >= 0 {
+		p.pos++
+	}
+	return strconv.ParseFloat(p.expr[start:p.pos], 64)
+}
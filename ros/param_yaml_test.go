@@ -0,0 +1,130 @@
+package ros
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const testParamsYAML = `
+rate: 10
+scale: 0.5
+enabled: true
+frame: base_link
+joints: [shoulder, elbow]
+limits:
+  max_angle: !degrees 90
+  min_angle: !radians -pi/4
+  speeds: {slow: 1, fast: 3}
+---
+extra: 1
+`
+
+func TestEvalRadians(t *testing.T) {
+	var tests = []struct {
+		expr     string
+		expected float64
+	}{
+		{"1.5", 1.5},
+		{"pi", math.Pi},
+		{"-pi/2", -math.Pi / 2},
+		{"2 * pi / 3", 2 * math.Pi / 3},
+		{"(1 + 1) * pi - 1", 2*math.Pi - 1},
+	}
+	for _, test := range tests {
+		value, err := evalRadians(test.expr)
+		if err != nil || math.Abs(value-test.expected) > 1e-12 {
+			t.Errorf("%s: %v, %v", test.expr, value, err)
+		}
+	}
+	for _, expr := range []string{"", "pi pi", "(pi", "tau", "1/"} {
+		if _, err := evalRadians(expr); err == nil {
+			t.Errorf("%q must be invalid", expr)
+		}
+	}
+}
+
+func TestLoadAndDumpParams(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	node := newTestNode(t, m, "/param_yaml_test")
+	defer node.Shutdown()
+
+	file := filepath.Join(os.TempDir(), "rosgo_test_params.yaml")
+	if err := ioutil.WriteFile(file, []byte(testParamsYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file)
+
+	// Dictionaries are updated, the other parameters are kept.
+	if err := node.SetParam("/robot/limits/max_speed", 2.0); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.LoadParamsFromYAML(file, "robot"); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"/robot/rate":               int32(10),
+		"/robot/scale":              0.5,
+		"/robot/enabled":            true,
+		"/robot/frame":              "base_link",
+		"/robot/joints":             []interface{}{"shoulder", "elbow"},
+		"/robot/limits/max_angle":   math.Pi / 2,
+		"/robot/limits/min_angle":   -math.Pi / 4,
+		"/robot/limits/speeds/fast": int32(3),
+		"/robot/limits/max_speed":   2.0,
+		"/robot/extra":              int32(1),
+	}
+	for name, value := range expected {
+		result, err := node.GetParam(name)
+		if err != nil || !reflect.DeepEqual(result, value) {
+			t.Errorf("%s: %#v, %v", name, result, err)
+		}
+	}
+
+	data, err := node.DumpParams("/robot/limits")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dumped map[string]interface{}
+	if err := yaml.Unmarshal(data, &dumped); err != nil {
+		t.Fatal(err)
+	}
+	if dumped["max_speed"] != 2.0 || dumped["max_angle"] != math.Pi/2 ||
+		!reflect.DeepEqual(dumped["speeds"], map[string]interface{}{"slow": 1, "fast": 3}) {
+		t.Error(string(data))
+	}
+
+	// An empty dictionary leaves the parameters alone, even at the root.
+	if err := ioutil.WriteFile(file, []byte("limits: {}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.LoadParamsFromYAML(file, "robot"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(file, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, ns := range []string{"/robot/limits", "/"} {
+		if err := node.LoadParamsFromYAML(file, ns); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if value, err := node.GetParam("/robot/limits/max_speed"); err != nil || value != 2.0 {
+		t.Error(value, err)
+	}
+
+	for _, bad := range []string{"value: 4294967296", "angle: !degrees right", "value: ~", "[1, 2]"} {
+		if err := ioutil.WriteFile(file, []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := node.LoadParamsFromYAML(file, "/"); err == nil {
+			t.Errorf("%q must fail", bad)
+		}
+	}
+}
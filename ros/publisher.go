@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -23,11 +24,12 @@ func (e *remoteSubscriberSessionError) Error() string {
 
 type defaultPublisher struct {
 	node               *defaultNode
+	logger             Logger
 	topic              string
 	msgType            MessageType
 	msgChan            chan []byte
 	shutdownChan       chan struct{}
-	sesssionIDCount    int
+	doneChan           chan struct{} // Closed when the publisher goroutine exits.
 	sessions           map[int]*remoteSubscriberSession
 	sessionChan        chan *remoteSubscriberSession
 	sessionErrorChan   chan error
@@ -35,37 +37,49 @@ type defaultPublisher struct {
 	listener           net.Listener
 	connectCallback    func(SingleSubscriberPublisher)
 	disconnectCallback func(SingleSubscriberPublisher)
+	latched            bool
+	lastMsg            []byte
+	queueSize          int
+	queuePolicy        QueuePolicy
 }
 
 func newDefaultPublisher(node *defaultNode,
 	topic string, msgType MessageType,
-	connectCallback, disconnectCallback func(SingleSubscriberPublisher)) *defaultPublisher {
+	connectCallback, disconnectCallback func(SingleSubscriberPublisher),
+	options ...PublisherOption) (*defaultPublisher, error) {
 	pub := new(defaultPublisher)
 	pub.node = node
+	pub.logger = node.logger.child("publisher")
 	pub.topic = topic
 	pub.msgType = msgType
 	pub.shutdownChan = make(chan struct{}, 10)
+	pub.doneChan = make(chan struct{})
 	pub.sessions = make(map[int]*remoteSubscriberSession)
-	pub.msgChan = make(chan []byte, 10)
 	pub.listenerErrorChan = make(chan error, 10)
 	pub.sessionChan = make(chan *remoteSubscriberSession, 10)
 	pub.sessionErrorChan = make(chan error, 10)
 	pub.connectCallback = connectCallback
 	pub.disconnectCallback = disconnectCallback
-	if listener, err := net.Listen("tcp", ":0"); err != nil {
-		panic(err)
-	} else {
-		pub.listener = listener
+	pub.queueSize = 10
+	pub.queuePolicy = QueueBlock
+	for _, option := range options {
+		option(pub)
+	}
+	pub.msgChan = make(chan []byte, pub.queueSize)
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return nil, err
 	}
-	return pub
+	pub.listener = listener
+	return pub, nil
 }
 
 func (pub *defaultPublisher) start(wg *sync.WaitGroup) {
-	logger := pub.node.logger
+	logger := pub.logger
 	logger.Debugf("Publisher goroutine for %s started.", pub.topic)
-	wg.Add(1)
 	defer func() {
 		logger.Debug("defaultPublisher.start exit")
+		close(pub.doneChan)
 		wg.Done()
 	}()
 
@@ -76,18 +90,26 @@ func (pub *defaultPublisher) start(wg *sync.WaitGroup) {
 		select {
 		case msg := <-pub.msgChan:
 			logger.Debug("Receive msgChan")
+			if pub.latched {
+				pub.lastMsg = msg
+			}
 			for _, s := range pub.sessions {
-				session := s
-				session.msgChan <- msg
+				if !enqueueMessage(s.msgChan, msg, pub.queuePolicy, s.doneChan) {
+					logger.Debugf("Dropped a message to %s", s.callerID)
+				}
 			}
 
 		case err := <-pub.listenerErrorChan:
-			logger.Debug("Listener closed unexpectedly: %s", err)
+			logger.Debugf("Listener closed unexpectedly: %s", err)
 			pub.listener.Close()
 			return
 
 		case s := <-pub.sessionChan:
 			pub.sessions[s.id] = s
+			if pub.latched && pub.lastMsg != nil {
+				// Queued messages are sent right after the response header.
+				enqueueMessage(s.msgChan, pub.lastMsg, pub.queuePolicy, s.doneChan)
+			}
 			go s.start()
 
 		case err := <-pub.sessionErrorChan:
@@ -107,7 +129,10 @@ func (pub *defaultPublisher) start(wg *sync.WaitGroup) {
 			}
 
 			for id, s := range pub.sessions {
-				s.quitChan <- struct{}{}
+				select {
+				case s.quitChan <- struct{}{}:
+				case <-s.doneChan:
+				}
 				delete(pub.sessions, id)
 			}
 			return
@@ -116,7 +141,7 @@ func (pub *defaultPublisher) start(wg *sync.WaitGroup) {
 }
 
 func (pub *defaultPublisher) listenRemoteSubscriber() {
-	logger := pub.node.logger
+	logger := pub.logger
 	logger.Debugf("Start listen %s.", pub.listener.Addr().String())
 	defer func() {
 		logger.Debug("defaultPublisher.listenRemoteSubscriber exit")
@@ -134,17 +159,34 @@ func (pub *defaultPublisher) listenRemoteSubscriber() {
 		}
 
 		logger.Debugf("Connected %s", conn.RemoteAddr().String())
-		id := pub.sesssionIDCount
-		pub.sesssionIDCount++
+		id := pub.node.busStats.nextConnectionID()
 		session := newRemoteSubscriberSession(pub, id, conn)
-		pub.sessionChan <- session
+		if !pub.addSession(session) {
+			return
+		}
+	}
+}
+
+// addSession hands a new session to the publisher goroutine. It closes the connection and
+// returns false if the publisher is shut down.
+func (pub *defaultPublisher) addSession(session *remoteSubscriberSession) bool {
+	select {
+	case <-pub.doneChan:
+	default:
+		select {
+		case pub.sessionChan <- session:
+			return true
+		case <-pub.doneChan:
+		}
 	}
+	session.conn.Close()
+	return false
 }
 
 func (pub *defaultPublisher) Publish(msg Message) {
 	var buf bytes.Buffer
 	_ = msg.Serialize(&buf)
-	pub.msgChan <- buf.Bytes()
+	enqueueMessage(pub.msgChan, buf.Bytes(), pub.queuePolicy, pub.doneChan)
 }
 
 func (pub *defaultPublisher) GetNumSubscribers() int {
@@ -155,6 +197,61 @@ func (pub *defaultPublisher) Shutdown() {
 	pub.shutdownChan <- struct{}{}
 }
 
+// acceptUDPROS validates the UDPROS parameters a subscriber sent with requestTopic and starts
+// a session sending datagrams to it. It returns the UDPROS parameters of the response.
+func (pub *defaultPublisher) acceptUDPROS(params []interface{}) ([]interface{}, error) {
+	if len(params) != 5 {
+		return nil, fmt.Errorf("UDPROS requires 5 parameters but %d were given", len(params))
+	}
+	headerBytes, ok1 := params[1].([]byte)
+	host, ok2 := params[2].(string)
+	port, ok3 := params[3].(int32)
+	maxDatagramSize, ok4 := params[4].(int32)
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		return nil, fmt.Errorf("malformed UDPROS parameters")
+	}
+	headers, err := decodeHeaderFields(headerBytes)
+	if err != nil {
+		return nil, err
+	}
+	headerMap := make(map[string]string)
+	for _, h := range headers {
+		headerMap[h.key] = h.value
+	}
+
+	conn, err := net.Dial("udp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		return nil, err
+	}
+	id := pub.node.busStats.nextConnectionID()
+	session := newRemoteSubscriberSession(pub, id, conn)
+	if err := session.checkHeader(headerMap); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	session.transport = TransportUDPROS
+	session.callerID = headerMap["callerid"]
+	session.connectionID = uint32(id)
+	session.maxDatagramSize = int(maxDatagramSize)
+	_, localPort, err := net.SplitHostPort(conn.LocalAddr().String())
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !pub.addSession(session) {
+		return nil, fmt.Errorf("publisher of %s is shut down", pub.topic)
+	}
+	p, _ := strconv.Atoi(localPort)
+	return []interface{}{
+		TransportUDPROS,
+		pub.node.hostname,
+		p,
+		id,
+		int(maxDatagramSize),
+		encodeHeaderFields(session.responseHeaders()),
+	}, nil
+}
+
 func (pub *defaultPublisher) hostAndPort() (string, string) {
 	_, port, err := net.SplitHostPort(pub.listener.Addr().String())
 	if err != nil {
@@ -167,16 +264,22 @@ func (pub *defaultPublisher) hostAndPort() (string, string) {
 type remoteSubscriberSession struct {
 	id                 int
 	conn               net.Conn
+	transport          string
+	connectionID       uint32
+	maxDatagramSize    int
+	udpMessageID       uint8
 	nodeID             string
 	callerID           string
 	topic              string
 	typeText           string
 	md5sum             string
 	typeName           string
-	sizeBytesSent      uint32
-	msgBytesSent       uint32
-	numSent            int64
+	busStats           *busStats
+	stats              *connectionStats
+	latching           bool
+	queuePolicy        QueuePolicy
 	quitChan           chan struct{}
+	doneChan           chan struct{}
 	msgChan            chan []byte
 	errorChan          chan error
 	logger             Logger
@@ -188,33 +291,37 @@ func newRemoteSubscriberSession(pub *defaultPublisher, id int, conn net.Conn) *r
 	session := new(remoteSubscriberSession)
 	session.id = id
 	session.conn = conn
+	session.transport = TransportTCPROS
 	session.nodeID = pub.node.qualifiedName
 	session.topic = pub.topic
 	session.typeText = pub.msgType.Text()
 	session.md5sum = pub.msgType.MD5Sum()
 	session.typeName = pub.msgType.Name()
-	session.sizeBytesSent = 0
-	session.msgBytesSent = 0
-	session.numSent = 0
+	session.busStats = pub.node.busStats
+	session.latching = pub.latched
+	session.queuePolicy = pub.queuePolicy
 	session.quitChan = make(chan struct{})
-	session.msgChan = make(chan []byte, 10)
+	session.doneChan = make(chan struct{})
+	session.msgChan = make(chan []byte, pub.queueSize)
 	session.errorChan = pub.sessionErrorChan
-	session.logger = pub.node.logger
+	session.logger = pub.logger
 	session.connectCallback = pub.connectCallback
 	session.disconnectCallback = pub.disconnectCallback
 	return session
 }
 
 type singleSubPub struct {
-	subName string
-	topic   string
-	msgChan chan []byte
+	subName  string
+	topic    string
+	msgChan  chan []byte
+	policy   QueuePolicy
+	doneChan chan struct{}
 }
 
 func (ssp *singleSubPub) Publish(msg Message) {
 	var buf bytes.Buffer
 	_ = msg.Serialize(&buf)
-	ssp.msgChan <- buf.Bytes()
+	enqueueMessage(ssp.msgChan, buf.Bytes(), ssp.policy, ssp.doneChan)
 }
 
 func (ssp *singleSubPub) GetSubscriberName() string {
@@ -225,18 +332,98 @@ func (ssp *singleSubPub) GetTopic() string {
 	return ssp.topic
 }
 
+// checkHeader validates the connection header sent by a subscriber.
+func (session *remoteSubscriberSession) checkHeader(headerMap map[string]string) error {
+	if headerMap["type"] != session.typeName && headerMap["type"] != "*" {
+		return fmt.Errorf("incompatible message type: does not match for topic %s: %s vs %s",
+			session.topic, session.typeName, headerMap["type"])
+	}
+	if headerMap["md5sum"] != session.md5sum && headerMap["md5sum"] != "*" {
+		return fmt.Errorf("incompatible message md5: does not match for topic %s: %s vs %s",
+			session.topic, session.md5sum, headerMap["md5sum"])
+	}
+	return nil
+}
+
+func (session *remoteSubscriberSession) responseHeaders() []header {
+	var resHeaders []header
+	resHeaders = append(resHeaders, header{"message_definition", session.typeText})
+	resHeaders = append(resHeaders, header{"callerid", session.nodeID})
+	latching := "0"
+	if session.latching {
+		latching = "1"
+	}
+	resHeaders = append(resHeaders, header{"latching", latching})
+	resHeaders = append(resHeaders, header{"md5sum", session.md5sum})
+	resHeaders = append(resHeaders, header{"topic", session.topic})
+	resHeaders = append(resHeaders, header{"type", session.typeName})
+	return resHeaders
+}
+
+// TCPROS handshake. UDPROS headers are exchanged in requestTopic instead.
+func (session *remoteSubscriberSession) handshake() error {
+	logger := session.logger
+	// 1. Read connection header
+	headers, err := readConnectionHeader(session.conn)
+	if err != nil {
+		return errors.New("failed to read connection header")
+	}
+	logger.Debug("TCPROS Connection Header:")
+	headerMap := make(map[string]string)
+	for _, h := range headers {
+		headerMap[h.key] = h.value
+		logger.Debugf("  `%s` = `%s`", h.key, h.value)
+	}
+	if err := session.checkHeader(headerMap); err != nil {
+		return err
+	}
+	session.callerID = headerMap["callerid"]
+
+	// 2. Return reponse header
+	resHeaders := session.responseHeaders()
+	logger.Debug("TCPROS Response Header")
+	for _, h := range resHeaders {
+		logger.Debugf("  `%s` = `%s`", h.key, h.value)
+	}
+	if err := writeConnectionHeader(resHeaders, session.conn); err != nil {
+		return errors.New("failed to write response header")
+	}
+	return nil
+}
+
+func (session *remoteSubscriberSession) writeMessage(msg []byte) error {
+	if session.transport == TransportUDPROS {
+		session.conn.SetDeadline(time.Now().Add(10 * time.Millisecond))
+		err := writeUDPROSMessage(session.conn, session.connectionID, session.udpMessageID, session.maxDatagramSize, msg)
+		session.udpMessageID++
+		return err
+	}
+	session.conn.SetDeadline(time.Now().Add(10 * time.Millisecond))
+	size := uint32(len(msg))
+	if err := binary.Write(session.conn, binary.LittleEndian, size); err != nil {
+		return err
+	}
+	session.logger.Debug(len(msg))
+	session.conn.SetDeadline(time.Now().Add(10 * time.Millisecond))
+	_, err := session.conn.Write(msg)
+	return err
+}
+
 func (session *remoteSubscriberSession) start() {
 	logger := session.logger
 	logger.Debug("remoteSubscriberSession.start enter")
 
 	ssp := &singleSubPub{
-		topic:   session.topic,
-		msgChan: session.msgChan,
+		topic:    session.topic,
+		msgChan:  session.msgChan,
+		policy:   session.queuePolicy,
+		doneChan: session.doneChan,
 		// callerID is filled in after header gets read later in this function.
 	}
 
 	defer func() {
 		logger.Debug("remoteSubscriberSession.start exit")
+		session.conn.Close()
 
 		if session.disconnectCallback != nil {
 			session.disconnectCallback(ssp)
@@ -255,85 +442,33 @@ func (session *remoteSubscriberSession) start() {
 			session.errorChan <- &remoteSubscriberSessionError{session, e}
 		}
 	}()
-	// 1. Read connection header
-	headers, err := readConnectionHeader(session.conn)
-	if err != nil {
-		panic(errors.New("failed to read connection header"))
-	}
-	logger.Debug("TCPROS Connection Header:")
-	headerMap := make(map[string]string)
-	for _, h := range headers {
-		headerMap[h.key] = h.value
-		logger.Debugf("  `%s` = `%s`", h.key, h.value)
-	}
+	defer close(session.doneChan)
 
-	if headerMap["type"] != session.typeName && headerMap["type"] != "*" {
-		panic(fmt.Errorf("incompatible message type: does not match for topic %s: %s vs %s",
-			session.topic, session.typeName, headerMap["type"]))
-	}
-
-	if headerMap["md5sum"] != session.md5sum && headerMap["md5sum"] != "*" {
-		panic(fmt.Errorf("incompatible message md5: does not match for topic %s: %s vs %s",
-			session.topic, session.md5sum, headerMap["md5sum"]))
+	if session.transport != TransportUDPROS {
+		if err := session.handshake(); err != nil {
+			panic(err)
+		}
 	}
-	session.callerID = headerMap["callerid"]
-	ssp.subName = headerMap["callerid"]
+	ssp.subName = session.callerID
+	session.stats = session.busStats.open(session.id, session.callerID, connectionOutbound, session.transport, session.topic)
+	defer session.stats.close()
 	if session.connectCallback != nil {
 		go session.connectCallback(ssp)
 	}
 
-	// 2. Return reponse header
-	var resHeaders []header
-	resHeaders = append(resHeaders, header{"message_definition", session.typeText})
-	resHeaders = append(resHeaders, header{"callerid", session.nodeID})
-	resHeaders = append(resHeaders, header{"latching", "0"})
-	resHeaders = append(resHeaders, header{"md5sum", session.md5sum})
-	resHeaders = append(resHeaders, header{"topic", session.topic})
-	resHeaders = append(resHeaders, header{"type", session.typeName})
-	logger.Debug("TCPROS Response Header")
-	for _, h := range resHeaders {
-		logger.Debugf("  `%s` = `%s`", h.key, h.value)
-	}
-	err = writeConnectionHeader(resHeaders, session.conn)
-	if err != nil {
-		panic(errors.New("failed to write response header"))
-	}
-
 	// 3. Start sending message
 	logger.Debug("Start sending messages...")
-	queueMaxSize := 100
-	queue := make(chan []byte, queueMaxSize)
 	for {
 		//logger.Debug("session.remoteSubscriberSession")
 		select {
-		case msg := <-session.msgChan:
-			logger.Debug("Receive msgChan")
-			if len(queue) == queueMaxSize {
-				<-queue
-			}
-			queue <- msg
-
 		case <-session.quitChan:
 			logger.Debug("Receive quitChan")
 			return
 
-		case msg := <-queue:
+		case msg := <-session.msgChan:
 			logger.Debug("writing")
 			logger.Debug(hex.EncodeToString(msg))
-			session.conn.SetDeadline(time.Now().Add(10 * time.Millisecond))
-			size := uint32(len(msg))
-			if err := binary.Write(session.conn, binary.LittleEndian, size); err != nil {
-				if neterr, ok := err.(net.Error); ok && neterr.Timeout() {
-					logger.Debug("timeout")
-					continue
-				} else {
-					logger.Error(err)
-					panic(err)
-				}
-			}
-			logger.Debug(len(msg))
-			session.conn.SetDeadline(time.Now().Add(10 * time.Millisecond))
-			if _, err := session.conn.Write(msg); err != nil {
+			if err := session.writeMessage(msg); err != nil {
 				if neterr, ok := err.(net.Error); ok && neterr.Timeout() {
 					logger.Debug("timeout")
 					continue
@@ -342,7 +477,7 @@ func (session *remoteSubscriberSession) start() {
 					panic(err)
 				}
 			}
-			logger.Debug(hex.EncodeToString(msg))
+			session.stats.addMessage(4 + len(msg))
 		}
 	}
 }
@@ -0,0 +1,155 @@
+package ros
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fetchrobotics/rosgo/master"
+)
+
+// A hand written std_msgs/String, so that tests don't depend on generated code.
+type testMessageType struct{}
+
+func (t *testMessageType) Text() string {
+	return "string data\n"
+}
+
+func (t *testMessageType) MD5Sum() string {
+	return "992ce8a1687cec8c8bd883ec73ca41d1"
+}
+
+func (t *testMessageType) Name() string {
+	return "std_msgs/String"
+}
+
+func (t *testMessageType) NewMessage() Message {
+	return new(testMessage)
+}
+
+var msgTestMessage = &testMessageType{}
+
+type testMessage struct {
+	Data string
+}
+
+func (m *testMessage) GetType() MessageType {
+	return msgTestMessage
+}
+
+func (m *testMessage) Serialize(buf *bytes.Buffer) error {
+	binary.Write(buf, binary.LittleEndian, uint32(len(m.Data)))
+	buf.WriteString(m.Data)
+	return nil
+}
+
+func (m *testMessage) Deserialize(buf *Reader) error {
+	var size uint32
+	if err := binary.Read(buf, binary.LittleEndian, &size); err != nil {
+		return err
+	}
+	m.Data = string(buf.Next(int(size)))
+	return nil
+}
+
+func newTestMaster(t *testing.T) *master.Master {
+	m, err := master.NewMaster("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func newTestNode(t *testing.T, m *master.Master, name string, opts ...NodeOption) *defaultNode {
+	logDir := filepath.Join(os.TempDir(), "rosgo_test_log")
+	node, err := newDefaultNode(name, []string{"__master:=" + m.URI(), "__ip:=127.0.0.1", "__log:=" + logDir}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return node
+}
+
+// spinUntil spins the node until the condition holds or the timeout expires.
+func spinUntil(node Node, timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		node.SpinOnce()
+	}
+	return cond()
+}
+
+func TestLatchedPublisher(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	node := newTestNode(t, m, "/latch_test")
+	defer node.Shutdown()
+
+	pub := node.NewPublisher("/latched", msgTestMessage, PublisherLatched())
+	pub.Publish(&testMessage{"first"})
+	pub.Publish(&testMessage{"last"})
+
+	var received []string
+	var header map[string]string
+	node.NewSubscriber("/latched", msgTestMessage, func(msg *testMessage, event MessageEvent) {
+		received = append(received, msg.Data)
+		header = event.ConnectionHeader
+	})
+	if !spinUntil(node, 5*time.Second, func() bool { return len(received) > 0 }) {
+		t.Fatal("latched message was not received")
+	}
+	if received[0] != "last" {
+		t.Error(received)
+	}
+	if header["latching"] != "1" {
+		t.Error(header)
+	}
+}
+
+func TestPublishAfterShutdown(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	node := newTestNode(t, m, "/publish_shutdown_test")
+	defer node.Shutdown()
+
+	// Publishers block on a full queue unless asked otherwise, like before queue options.
+	defaults := node.NewPublisher("/defaults", msgTestMessage).(*defaultPublisher)
+	if defaults.queuePolicy != QueueBlock || defaults.queueSize != 10 {
+		t.Error(defaults.queuePolicy, defaults.queueSize)
+	}
+
+	pub := node.NewPublisher("/blocking", msgTestMessage, PublisherQueueSize(1))
+	pub.Shutdown()
+	done := make(chan struct{})
+	go func() {
+		// The queue fills up once the publisher goroutine is gone.
+		for i := 0; i < 3; i++ {
+			pub.Publish(&testMessage{"blocked"})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Publish blocked after Shutdown")
+	}
+
+	// Subscribers connecting after Shutdown are turned away.
+	params := []interface{}{
+		TransportUDPROS,
+		encodeHeaderFields([]header{{"type", msgTestMessage.Name()}, {"md5sum", msgTestMessage.MD5Sum()}}),
+		"127.0.0.1",
+		int32(9),
+		int32(1500),
+	}
+	for i := 0; i < 2*cap(pub.(*defaultPublisher).sessionChan); i++ {
+		if _, err := pub.(*defaultPublisher).acceptUDPROS(params); err == nil {
+			t.Fatal("a session was added after Shutdown")
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package ros
+
+import (
+	"sync"
+)
+
+// QueuePolicy selects what happens to a new message when a publisher or subscriber queue is full.
+type QueuePolicy int
+
+const (
+	// QueueDropOldest discards the oldest queued message to make room for the new one.
+	QueueDropOldest QueuePolicy = iota
+	// QueueDropNewest discards the new message.
+	QueueDropNewest
+	// QueueBlock waits until there is room in the queue.
+	QueueBlock
+)
+
+// enqueueMessage pushes msg to queue according to the policy. It returns false if a message
+// was dropped. A blocking push gives up when quitChan is closed.
+func enqueueMessage(queue chan []byte, msg []byte, policy QueuePolicy, quitChan <-chan struct{}) bool {
+	dropped := false
+	for {
+		select {
+		case queue <- msg:
+			return !dropped
+		default:
+		}
+		switch policy {
+		case QueueDropNewest:
+			return false
+		case QueueDropOldest:
+			// The consumer may take the oldest message first, then just retry.
+			select {
+			case <-queue:
+				dropped = true
+			default:
+			}
+		default:
+			select {
+			case queue <- msg:
+				return true
+			case <-quitChan:
+				return false
+			}
+		}
+	}
+}
+
+// messageQueue is a bounded FIFO of received messages waiting for their callbacks.
+type messageQueue struct {
+	mutex  sync.Mutex
+	cond   *sync.Cond
+	events []messageEvent
+	size   int
+	policy QueuePolicy
+	closed bool
+}
+
+func newMessageQueue(size int, policy QueuePolicy) *messageQueue {
+	q := new(messageQueue)
+	q.cond = sync.NewCond(&q.mutex)
+	q.size = size
+	q.policy = policy
+	return q
+}
+
+// push adds the event to the queue and returns true if the queue grew, i.e. one more
+// message is waiting to be popped. With QueueBlock, push waits until there is room.
+func (q *messageQueue) push(ev messageEvent) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for !q.closed && len(q.events) >= q.size {
+		switch q.policy {
+		case QueueDropNewest:
+			return false
+		case QueueDropOldest:
+			q.events = append(q.events[1:], ev)
+			return false
+		default:
+			q.cond.Wait()
+		}
+	}
+	if q.closed {
+		return false
+	}
+	q.events = append(q.events, ev)
+	return true
+}
+
+func (q *messageQueue) pop() (messageEvent, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if len(q.events) == 0 {
+		return messageEvent{}, false
+	}
+	ev := q.events[0]
+	q.events = q.events[1:]
+	q.cond.Signal()
+	return ev, true
+}
+
+// close drops all the queued messages and releases blocked producers.
+func (q *messageQueue) close() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.closed = true
+	q.events = nil
+	q.cond.Broadcast()
+}
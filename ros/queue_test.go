@@ -0,0 +1,103 @@
+package ros
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEnqueueMessage(t *testing.T) {
+	queue := make(chan []byte, 2)
+	for i := 0; i < 3; i++ {
+		enqueueMessage(queue, []byte{byte(i)}, QueueDropOldest, nil)
+	}
+	if msg := <-queue; msg[0] != 1 {
+		t.Error(msg)
+	}
+	<-queue
+
+	for i := 0; i < 3; i++ {
+		ok := enqueueMessage(queue, []byte{byte(i)}, QueueDropNewest, nil)
+		if ok != (i < 2) {
+			t.Error(i, ok)
+		}
+	}
+	if msg := <-queue; msg[0] != 0 {
+		t.Error(msg)
+	}
+	<-queue
+
+	quitChan := make(chan struct{})
+	enqueueMessage(queue, []byte{0}, QueueBlock, quitChan)
+	enqueueMessage(queue, []byte{1}, QueueBlock, quitChan)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(quitChan)
+	}()
+	if enqueueMessage(queue, []byte{2}, QueueBlock, quitChan) {
+		t.Error("blocking enqueue must give up when quitChan is closed")
+	}
+}
+
+func TestMessageQueue(t *testing.T) {
+	q := newMessageQueue(2, QueueDropOldest)
+	for i := 0; i < 3; i++ {
+		grew := q.push(messageEvent{bytes: []byte{byte(i)}})
+		if grew != (i < 2) {
+			t.Error(i, grew)
+		}
+	}
+	if ev, _ := q.pop(); ev.bytes[0] != 1 {
+		t.Error(ev.bytes)
+	}
+	if ev, _ := q.pop(); ev.bytes[0] != 2 {
+		t.Error(ev.bytes)
+	}
+	if _, ok := q.pop(); ok {
+		t.Error("queue must be empty")
+	}
+
+	q = newMessageQueue(1, QueueBlock)
+	q.push(messageEvent{})
+	done := make(chan bool)
+	go func() {
+		done <- q.push(messageEvent{})
+	}()
+	select {
+	case <-done:
+		t.Fatal("push must block while the queue is full")
+	case <-time.After(10 * time.Millisecond):
+	}
+	q.pop()
+	if grew := <-done; !grew {
+		t.Error("blocked push must succeed after pop")
+	}
+	q.close()
+	if q.push(messageEvent{}) {
+		t.Error("push to a closed queue must fail")
+	}
+}
+
+func TestSubscriberLatestOnly(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	node := newTestNode(t, m, "/queue_test")
+	defer node.Shutdown()
+
+	pub := node.NewPublisher("/latest", msgTestMessage)
+	var received []string
+	node.NewSubscriber("/latest", msgTestMessage, func(msg *testMessage) {
+		received = append(received, msg.Data)
+	}, SubscriberQueueSize(1), SubscriberQueuePolicy(QueueDropOldest))
+	// Let the subscriber connect.
+	time.Sleep(500 * time.Millisecond)
+
+	for i := 0; i < 5; i++ {
+		pub.Publish(&testMessage{strconv.Itoa(i)})
+	}
+	time.Sleep(100 * time.Millisecond)
+	spinUntil(node, 100*time.Millisecond, func() bool { return false })
+	if len(received) != 1 || received[0] != "4" {
+		t.Error(received)
+	}
+}
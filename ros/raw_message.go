@@ -0,0 +1,63 @@
+package ros
+
+import (
+	"bytes"
+)
+
+// RawMessage is a message of any type kept serialized, for recorders, relays and bridges.
+// Subscribers of AnyMessageType receive RawMessages with the type of the publisher.
+type RawMessage struct {
+	Type       string // Type name, like std_msgs/String.
+	MD5Sum     string
+	Definition string // Full message definition.
+	Data       []byte // Serialized message.
+}
+
+// GetType returns the type of the message as given by Type, MD5Sum and Definition, so that a
+// publisher of that type can send it again.
+func (m *RawMessage) GetType() MessageType {
+	return &rawMessageType{m.Type, m.MD5Sum, m.Definition}
+}
+
+func (m *RawMessage) Serialize(buf *bytes.Buffer) error {
+	_, err := buf.Write(m.Data)
+	return err
+}
+
+func (m *RawMessage) Deserialize(buf *Reader) error {
+	m.Data = buf.Next(buf.Len())
+	return nil
+}
+
+// setConnectionHeader fills the type of the message from the header of the publisher.
+func (m *RawMessage) setConnectionHeader(headers map[string]string) {
+	m.Type = headers["type"]
+	m.MD5Sum = headers["md5sum"]
+	m.Definition = headers["message_definition"]
+}
+
+type rawMessageType struct {
+	name       string
+	md5sum     string
+	definition string
+}
+
+func (t *rawMessageType) Text() string {
+	return t.definition
+}
+
+func (t *rawMessageType) MD5Sum() string {
+	return t.md5sum
+}
+
+func (t *rawMessageType) Name() string {
+	return t.name
+}
+
+func (t *rawMessageType) NewMessage() Message {
+	return &RawMessage{Type: t.name, MD5Sum: t.md5sum, Definition: t.definition}
+}
+
+// AnyMessageType subscribes to topics of any type, the callbacks receive *RawMessage. The
+// subscriber connects with the wildcard type and md5sum "*".
+var AnyMessageType MessageType = &rawMessageType{"*", "*", ""}
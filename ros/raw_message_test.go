@@ -0,0 +1,49 @@
+package ros
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRawSubscriber(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	node := newTestNode(t, m, "/raw_test")
+	defer node.Shutdown()
+
+	pub := node.NewPublisher("/chatter", msgTestMessage)
+	var raws []*RawMessage
+	node.NewSubscriber("/chatter", AnyMessageType, func(msg *RawMessage) {
+		raws = append(raws, msg)
+	})
+	var relayed []string
+	node.NewSubscriber("/relay", msgTestMessage, func(msg *testMessage) {
+		relayed = append(relayed, msg.Data)
+	})
+	// Let the subscriber connect.
+	time.Sleep(500 * time.Millisecond)
+
+	pub.Publish(&testMessage{"hello"})
+	if !spinUntil(node, 5*time.Second, func() bool { return len(raws) > 0 }) {
+		t.Fatal("no raw message received")
+	}
+	raw := raws[0]
+	if raw.Type != "std_msgs/String" || raw.MD5Sum != msgTestMessage.MD5Sum() || raw.Definition != msgTestMessage.Text() {
+		t.Error(raw.Type, raw.MD5Sum, raw.Definition)
+	}
+	var msg testMessage
+	if err := msg.Deserialize(NewReader(raw.Data)); err != nil || msg.Data != "hello" {
+		t.Error(msg, err)
+	}
+
+	// A raw message is published again with its own type.
+	relay := node.NewPublisher("/relay", raw.GetType())
+	time.Sleep(500 * time.Millisecond)
+	relay.Publish(raw)
+	if !spinUntil(node, 5*time.Second, func() bool { return len(relayed) > 0 }) {
+		t.Fatal("relayed message not received")
+	}
+	if relayed[0] != "hello" {
+		t.Error(relayed)
+	}
+}
@@ -1,20 +1,27 @@
 package ros
 
 import (
+	"context"
 	"time"
 )
 
 // Node defines interface for a ros node
+//
+// The constructors of publishers and subscribers exit the process when they fail, for example
+// when the master is unreachable; their E variants return the error instead.
 type Node interface {
 
-	// NewPublisher creates a publisher for specified topic and message type.
-	NewPublisher(topic string, msgType MessageType) Publisher
+	// NewPublisher creates a publisher for specified topic and message type, it logs the
+	// error and returns nil if it fails.
+	NewPublisher(topic string, msgType MessageType, options ...PublisherOption) Publisher
+	NewPublisherE(topic string, msgType MessageType, options ...PublisherOption) (Publisher, error)
 
 	// NewPublisherWithCallbacks creates a publisher which gives you callbacks when subscribers
 	// connect and disconnect.  The callbacks are called in their own
 	// goroutines, so they don't need to return immediately to let the
 	// connection proceed.
-	NewPublisherWithCallbacks(topic string, msgType MessageType, connectCallback, disconnectCallback func(SingleSubscriberPublisher)) Publisher
+	NewPublisherWithCallbacks(topic string, msgType MessageType, connectCallback, disconnectCallback func(SingleSubscriberPublisher), options ...PublisherOption) Publisher
+	NewPublisherWithCallbacksE(topic string, msgType MessageType, connectCallback, disconnectCallback func(SingleSubscriberPublisher), options ...PublisherOption) (Publisher, error)
 
 	// NewSubscriber creates a subscriber to specified topic, where
 	// the messages are of a given type. callback should be a function
@@ -23,13 +30,26 @@ type Node interface {
 	// the normal case, and the argument should be of the generated message type.
 	// If the function takes 2 arguments, the first argument should be of the
 	// generated message type and the second argument should be of type MessageEvent.
-	NewSubscriber(topic string, msgType MessageType, callback interface{}) Subscriber
+	// It logs the error and returns nil if it fails.
+	NewSubscriber(topic string, msgType MessageType, callback interface{}, options ...SubscriberOption) Subscriber
+	NewSubscriberE(topic string, msgType MessageType, callback interface{}, options ...SubscriberOption) (Subscriber, error)
 	NewServiceClient(service string, srvType ServiceType, options ...ServiceClientOption) ServiceClient
+	// NewServiceServer creates a service server, it logs the error and returns nil if it fails.
 	NewServiceServer(service string, srvType ServiceType, callback interface{}, options ...ServiceServerOption) ServiceServer
+	NewServiceServerE(service string, srvType ServiceType, callback interface{}, options ...ServiceServerOption) (ServiceServer, error)
+
+	// NewTimer creates a started timer which calls callback every period, or only once if
+	// oneshot is true. Callbacks go through the node's global callback queue.
+	NewTimer(period Duration, callback func(TimerEvent), oneshot bool) Timer
+	// NewWallTimer creates a timer like NewTimer which always follows the wall clock.
+	NewWallTimer(period Duration, callback func(TimerEvent), oneshot bool) Timer
 
 	OK() bool
 	SpinOnce()
 	Spin()
+	// NewAsyncSpinner creates a spinner running callbacks in workers goroutines between its Start
+	// and Stop. It serves queue, or the node's global callback queue if queue is nil.
+	NewAsyncSpinner(workers int, queue *CallbackQueue) Spinner
 	Shutdown()
 
 	GetParam(name string) (interface{}, error)
@@ -38,6 +58,41 @@ type Node interface {
 	SearchParam(name string) (string, error)
 	DeleteParam(name string) error
 
+	// SubscribeParam subscribes to the changes of a parameter. callback may be nil, otherwise
+	// it's called from Spin with the name and new value of the parameter or of its descendants.
+	SubscribeParam(name string, callback ParamCallback) error
+	UnsubscribeParam(name string) error
+	// GetParamCached reads a parameter from the node cache, which is kept up to date by the
+	// master after the first call.
+	GetParamCached(name string) (interface{}, error)
+	// LoadParamsFromYAML sets the parameters of a YAML file in namespace, like rosparam load.
+	LoadParamsFromYAML(file string, namespace string) error
+	// DumpParams returns the parameters in namespace as YAML, like rosparam dump.
+	DumpParams(namespace string) ([]byte, error)
+	// GetParamInt, GetParamFloat64, GetParamBool, GetParamString and GetParamStringSlice read
+	// a parameter of a given type, they return ParamErrors if it doesn't fit.
+	GetParamInt(name string) (int, error)
+	GetParamFloat64(name string) (float64, error)
+	GetParamBool(name string) (bool, error)
+	GetParamString(name string) (string, error)
+	GetParamStringSlice(name string) ([]string, error)
+	// GetParamOr reads a parameter of the type of defaultValue, or returns defaultValue.
+	GetParamOr(name string, defaultValue interface{}) interface{}
+	// BindParams fills the fields of a struct tagged with `rosparam:"name"` from the parameters
+	// in namespace, recursively. It returns ParamErrors with the errors of every field.
+	BindParams(namespace string, v interface{}) error
+
+	// GetSystemState, GetTopicTypes, GetPublishedTopics, LookupNode and GetMasterURI query the
+	// graph from the master.
+	GetSystemState() (*SystemState, error)
+	GetTopicTypes() ([]TopicInfo, error)
+	GetPublishedTopics(subgraph string) ([]TopicInfo, error)
+	LookupNode(name string) (string, error)
+	GetMasterURI() (string, error)
+	// NewGraphWatcher polls the graph every period and calls callback for each change. Callbacks
+	// go through the node's global callback queue.
+	NewGraphWatcher(period time.Duration, callback func(GraphEvent)) GraphWatcher
+
 	Logger() Logger
 
 	NonRosArgs() []string
@@ -47,6 +102,24 @@ type Node interface {
 // NodeOption allows to customize created nodes.
 type NodeOption func(n *defaultNode)
 
+// NodeLogFileRotation rotates the node's log file when it would grow over maxSize bytes,
+// keeping maxBackups previous files. A maxSize of 0 disables the rotation, a negative one
+// disables the log file.
+func NodeLogFileRotation(maxSize int64, maxBackups int) NodeOption {
+	return func(n *defaultNode) {
+		n.logFileMaxSize = maxSize
+		n.logFileMaxBackups = maxBackups
+	}
+}
+
+// NodeMasterCheckPeriod changes the period of the master watchdog, which registers the node
+// again when the master restarts. A period of 0 disables the watchdog.
+func NodeMasterCheckPeriod(period time.Duration) NodeOption {
+	return func(n *defaultNode) {
+		n.masterCheckPeriod = period
+	}
+}
+
 // NodeServiceClientOptions specifies default options applied to the service clients created in this node.
 func NodeServiceClientOptions(opts ...ServiceClientOption) NodeOption {
 	return func(n *defaultNode) {
@@ -105,5 +178,8 @@ type ServiceServer interface {
 
 type ServiceClient interface {
 	Call(srv Service) error
+	// CallContext calls the service, giving up when ctx is done. The returned error then
+	// wraps ctx.Err().
+	CallContext(ctx context.Context, srv Service) error
 	Shutdown()
 }
@@ -0,0 +1,234 @@
+package ros
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+)
+
+// A hand written rosgraph_msgs/Log, the ros package can't depend on generated messages.
+type logMessageType struct{}
+
+func (t *logMessageType) Text() string {
+	return `byte DEBUG=1
+byte INFO=2
+byte WARN=4
+byte ERROR=8
+byte FATAL=16
+Header header
+byte level
+string name
+string msg
+string file
+string function
+uint32 line
+string[] topics
+
+================================================================================
+MSG: std_msgs/Header
+uint32 seq
+time stamp
+string frame_id
+`
+}
+
+func (t *logMessageType) MD5Sum() string {
+	return "acffd30cd6b6de30f120938c17c593fb"
+}
+
+func (t *logMessageType) Name() string {
+	return "rosgraph_msgs/Log"
+}
+
+func (t *logMessageType) NewMessage() Message {
+	return new(logMessage)
+}
+
+var msgLog = &logMessageType{}
+
+// Severity levels of rosgraph_msgs/Log.
+const (
+	logMessageDebug uint8 = 1
+	logMessageInfo  uint8 = 2
+	logMessageWarn  uint8 = 4
+	logMessageError uint8 = 8
+	logMessageFatal uint8 = 16
+)
+
+var logMessageLevels = [...]uint8{logMessageDebug, logMessageInfo, logMessageWarn, logMessageError, logMessageFatal}
+
+type logMessageHeader struct {
+	Seq     uint32 `rosmsg:"seq:uint32"`
+	Stamp   Time   `rosmsg:"stamp:time"`
+	FrameID string `rosmsg:"frame_id:string"`
+}
+
+type logMessage struct {
+	Header   logMessageHeader `rosmsg:"header:Header"`
+	Level    uint8            `rosmsg:"level:byte"`
+	Name     string           `rosmsg:"name:string"`
+	Msg      string           `rosmsg:"msg:string"`
+	File     string           `rosmsg:"file:string"`
+	Function string           `rosmsg:"function:string"`
+	Line     uint32           `rosmsg:"line:uint32"`
+	Topics   []string         `rosmsg:"topics:string[]"`
+}
+
+func (m *logMessage) GetType() MessageType {
+	return msgLog
+}
+
+func (m *logMessage) Serialize(buf *bytes.Buffer) error {
+	binary.Write(buf, binary.LittleEndian, m.Header.Seq)
+	binary.Write(buf, binary.LittleEndian, m.Header.Stamp.Sec)
+	binary.Write(buf, binary.LittleEndian, m.Header.Stamp.NSec)
+	writeString(buf, m.Header.FrameID)
+	binary.Write(buf, binary.LittleEndian, m.Level)
+	writeString(buf, m.Name)
+	writeString(buf, m.Msg)
+	writeString(buf, m.File)
+	writeString(buf, m.Function)
+	binary.Write(buf, binary.LittleEndian, m.Line)
+	binary.Write(buf, binary.LittleEndian, uint32(len(m.Topics)))
+	for _, topic := range m.Topics {
+		writeString(buf, topic)
+	}
+	return nil
+}
+
+func (m *logMessage) Deserialize(buf *Reader) error {
+	var err error
+	if err = binary.Read(buf, binary.LittleEndian, &m.Header.Seq); err != nil {
+		return err
+	}
+	if err = binary.Read(buf, binary.LittleEndian, &m.Header.Stamp.Sec); err != nil {
+		return err
+	}
+	if err = binary.Read(buf, binary.LittleEndian, &m.Header.Stamp.NSec); err != nil {
+		return err
+	}
+	if m.Header.FrameID, err = readString(buf); err != nil {
+		return err
+	}
+	if err = binary.Read(buf, binary.LittleEndian, &m.Level); err != nil {
+		return err
+	}
+	for _, s := range []*string{&m.Name, &m.Msg, &m.File, &m.Function} {
+		if *s, err = readString(buf); err != nil {
+			return err
+		}
+	}
+	if err = binary.Read(buf, binary.LittleEndian, &m.Line); err != nil {
+		return err
+	}
+	var size uint32
+	if err = binary.Read(buf, binary.LittleEndian, &size); err != nil {
+		return err
+	}
+	m.Topics = make([]string, 0, size)
+	for i := 0; i < int(size); i++ {
+		topic, err := readString(buf)
+		if err != nil {
+			return err
+		}
+		m.Topics = append(m.Topics, topic)
+	}
+	return nil
+}
+
+// rosoutOutput publishes the records of the node's logger to /rosout. Records are published
+// by a goroutine of their own, as the logger may be called with the node's locks held.
+type rosoutOutput struct {
+	node       *defaultNode
+	pub        *defaultPublisher
+	recordChan chan rosoutRecord
+	quitChan   chan struct{}
+	seq        uint32
+}
+
+type rosoutRecord struct {
+	*logRecord
+	stamp Time
+}
+
+func newRosoutOutput(node *defaultNode, pub *defaultPublisher) *rosoutOutput {
+	out := new(rosoutOutput)
+	out.node = node
+	out.pub = pub
+	out.recordChan = make(chan rosoutRecord, 100)
+	out.quitChan = make(chan struct{})
+	return out
+}
+
+func (out *rosoutOutput) write(record *logRecord) {
+	select {
+	case out.recordChan <- rosoutRecord{record, Now()}:
+	default:
+		// Drop the record rather than blocking the caller.
+	}
+}
+
+func (out *rosoutOutput) run() {
+	for {
+		select {
+		case record := <-out.recordChan:
+			out.publish(record)
+		case <-out.quitChan:
+			return
+		}
+	}
+}
+
+func (out *rosoutOutput) close() {
+	close(out.quitChan)
+}
+
+func (out *rosoutOutput) publish(record rosoutRecord) {
+	out.seq++
+	msg := &logMessage{
+		Level:    logMessageLevels[record.level],
+		Name:     out.node.qualifiedName,
+		Msg:      record.msg,
+		File:     record.file,
+		Function: record.function,
+		Line:     uint32(record.line),
+		Topics:   out.node.topics(),
+	}
+	msg.Header.Seq = out.seq
+	msg.Header.Stamp = record.stamp
+	out.pub.Publish(msg)
+}
+
+// topics returns the topics the node publishes or subscribes, sorted.
+func (node *defaultNode) topics() []string {
+	set := make(map[string]bool)
+	node.publishersMutex.RLock()
+	for topic := range node.publishers {
+		set[topic] = true
+	}
+	node.publishersMutex.RUnlock()
+	node.subscribersMutex.RLock()
+	for topic := range node.subscribers {
+		set[topic] = true
+	}
+	node.subscribersMutex.RUnlock()
+	topics := make([]string, 0, len(set))
+	for topic := range set {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// startRosout publishes the node's log messages to /rosout. The publisher logs only to the
+// standard logger, otherwise its own messages would be published again.
+func (node *defaultNode) startRosout(logger *defaultLogger) {
+	pub, err := node.NewPublisherE("/rosout", msgLog, publisherLogger(NewDefaultLogger()))
+	if err != nil {
+		logger.Warnf("Failed to publish /rosout: %v", err)
+		return
+	}
+	node.rosout = newRosoutOutput(node, pub.(*defaultPublisher))
+	go node.rosout.run()
+	logger.addOutput(node.rosout)
+}
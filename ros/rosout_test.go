@@ -0,0 +1,70 @@
+package ros
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogMessageSerialization(t *testing.T) {
+	msg := &logMessage{
+		Level:    logMessageWarn,
+		Name:     "/node",
+		Msg:      "message",
+		File:     "file.go",
+		Function: "main.f",
+		Line:     42,
+		Topics:   []string{"/a", "/b"},
+	}
+	msg.Header.Seq = 3
+	msg.Header.Stamp = NewTime(10, 20)
+	var buf bytes.Buffer
+	msg.Serialize(&buf)
+	var result logMessage
+	if err := result.Deserialize(NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(msg, &result) {
+		t.Error(result)
+	}
+}
+
+func TestRosout(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	listener := newTestNode(t, m, "/rosout_listener")
+	defer listener.Shutdown()
+	node := newTestNode(t, m, "/rosout_test")
+	defer node.Shutdown()
+	node.NewSubscriber("/chatter", msgTestMessage, func(msg *testMessage) {})
+
+	var logs []*logMessage
+	listener.NewSubscriber("/rosout", msgLog, func(msg *logMessage) {
+		if msg.Name == "/rosout_test" {
+			logs = append(logs, msg)
+		}
+	})
+	// Let the subscriber connect.
+	time.Sleep(500 * time.Millisecond)
+
+	node.Logger().Debug("hidden")
+	node.Logger().Warnf("answer is %d", 42)
+	if !spinUntil(listener, 5*time.Second, func() bool { return len(logs) > 0 }) {
+		t.Fatal("no log message received")
+	}
+	msg := logs[0]
+	if msg.Level != logMessageWarn || msg.Msg != "answer is 42" {
+		t.Error(msg.Level, msg.Msg)
+	}
+	if !strings.HasSuffix(msg.File, "rosout_test.go") || !strings.HasSuffix(msg.Function, "TestRosout") || msg.Line == 0 {
+		t.Error(msg.File, msg.Function, msg.Line)
+	}
+	if !reflect.DeepEqual(msg.Topics, []string{"/chatter", "/rosout"}) {
+		t.Error(msg.Topics)
+	}
+	if msg.Header.Stamp.IsZero() {
+		t.Error("message has no stamp")
+	}
+}
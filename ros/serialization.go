@@ -1,6 +1,8 @@
 package ros
 
 import (
+	"bytes"
+	"encoding/binary"
 	"io"
 )
 
@@ -51,3 +53,20 @@ func (r *Reader) Next(n int) []byte {
 func (r *Reader) Len() int {
 	return len(r.s) - r.i
 }
+
+// writeString and readString serialize the strings of the hand written messages.
+func writeString(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.LittleEndian, uint32(len(s)))
+	buf.WriteString(s)
+}
+
+func readString(r *Reader) (string, error) {
+	var size uint32
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return "", err
+	}
+	if int(size) > r.Len() {
+		return "", io.ErrUnexpectedEOF
+	}
+	return string(r.Next(int(size))), nil
+}
@@ -2,12 +2,13 @@ package ros
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
-	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/url"
+	"sync"
 	"time"
 )
 
@@ -18,6 +19,16 @@ type defaultServiceClient struct {
 	masterURI  string
 	nodeID     string
 	tcpTimeout time.Duration
+	persistent bool
+	conn       net.Conn // Connection of a persistent client, nil until the first call.
+	connMutex  sync.Mutex
+}
+
+// serviceError is the error message returned by the service handler.
+type serviceError string
+
+func (e serviceError) Error() string {
+	return string(e)
 }
 
 func newDefaultServiceClient(logger Logger, nodeID string, masterURI string, service string, srvType ServiceType, options ...ServiceClientOption) *defaultServiceClient {
@@ -35,29 +46,107 @@ func newDefaultServiceClient(logger Logger, nodeID string, masterURI string, ser
 	return client
 }
 
+// contextError is returned when a call is aborted by its context.
+type contextError struct {
+	ctxErr error
+	err    error
+}
+
+func (e *contextError) Error() string {
+	return fmt.Sprintf("%v: %v", e.ctxErr, e.err)
+}
+
+// Unwrap returns the error of the context.
+func (e *contextError) Unwrap() error {
+	return e.ctxErr
+}
+
 func (c *defaultServiceClient) Call(srv Service) error {
-	logger := c.logger
+	return c.CallContext(context.Background(), srv)
+}
+
+// CallContext calls the service like Call, aborting the call when ctx is done. The deadline of
+// ctx bounds the whole call, while the TCP timeout still applies to each operation.
+func (c *defaultServiceClient) CallContext(ctx context.Context, srv Service) error {
+	err := c.callContext(ctx, srv)
+	if err == nil {
+		return nil
+	}
+	if d, ok := ctx.Deadline(); ok && !time.Now().Before(d) {
+		// An operation timed out at the deadline, the context is about to expire.
+		<-ctx.Done()
+	}
+	if ctx.Err() != nil && err != ctx.Err() {
+		return &contextError{ctx.Err(), err}
+	}
+	return err
+}
+
+func (c *defaultServiceClient) callContext(ctx context.Context, srv Service) error {
+	if !c.persistent {
+		conn, err := c.connect(ctx)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		return c.call(ctx, conn, srv)
+	}
 
-	result, err := callRosAPI(c.masterURI, "lookupService", c.nodeID, c.service)
+	c.connMutex.Lock()
+	defer c.connMutex.Unlock()
+	if c.conn != nil {
+		err := c.call(ctx, c.conn, srv)
+		if _, ok := err.(serviceError); ok || err == nil {
+			return err
+		}
+		c.logger.Debugf("Persistent connection to %s failed: %v", c.service, err)
+		c.conn.Close()
+		c.conn = nil
+		if neterr, ok := err.(net.Error); (ok && neterr.Timeout()) || ctx.Err() != nil {
+			// The server may still handle the request, don't send it twice.
+			return err
+		}
+		// The server went away, look it up again.
+	}
+	conn, err := c.connect(ctx)
 	if err != nil {
 		return err
 	}
+	err = c.call(ctx, conn, srv)
+	if _, ok := err.(serviceError); ok || err == nil {
+		c.conn = conn
+	} else {
+		conn.Close()
+	}
+	return err
+}
+
+// connect looks up the service and makes a connection to the server.
+func (c *defaultServiceClient) connect(ctx context.Context) (net.Conn, error) {
+	logger := c.logger
+
+	result, err := callRosAPIContext(ctx, c.masterURI, "lookupService", c.nodeID, c.service)
+	if err != nil {
+		return nil, err
+	}
 
 	serviceRawURL, converted := result.(string)
 	if !converted {
-		return fmt.Errorf("Result of 'lookupService' is not a string")
+		return nil, fmt.Errorf("Result of 'lookupService' is not a string")
 	}
 	var serviceURL *url.URL
 	serviceURL, err = url.Parse(serviceRawURL)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var conn net.Conn
-	conn, err = net.Dial("tcp", serviceURL.Host)
+	var dialer net.Dialer
+	conn, err = dialer.DialContext(ctx, "tcp", serviceURL.Host)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer watchContext(ctx, conn)()
 
 	// 1. Write connection header
 	var headers []header
@@ -67,19 +156,24 @@ func (c *defaultServiceClient) Call(srv Service) error {
 	headers = append(headers, header{"md5sum", md5sum})
 	headers = append(headers, header{"type", msgType})
 	headers = append(headers, header{"callerid", c.nodeID})
+	if c.persistent {
+		headers = append(headers, header{"persistent", "1"})
+	}
 	logger.Debug("TCPROS Connection Header")
 	for _, h := range headers {
 		logger.Debugf("  `%s` = `%s`", h.key, h.value)
 	}
-	conn.SetDeadline(time.Now().Add(c.tcpTimeout))
+	conn.SetDeadline(c.deadline(ctx))
 	if err := writeConnectionHeader(headers, conn); err != nil {
-		return err
+		conn.Close()
+		return nil, err
 	}
 
 	// 2. Read reponse header
-	conn.SetDeadline(time.Now().Add(c.tcpTimeout))
+	conn.SetDeadline(c.deadline(ctx))
 	if resHeaders, err := readConnectionHeader(conn); err != nil {
-		return err
+		conn.Close()
+		return nil, err
 	} else {
 		logger.Debug("TCPROS Response Header:")
 		resHeaderMap := make(map[string]string)
@@ -88,50 +182,58 @@ func (c *defaultServiceClient) Call(srv Service) error {
 			logger.Debugf("  `%s` = `%s`", h.key, h.value)
 		}
 		if resHeaderMap["type"] != msgType || resHeaderMap["md5sum"] != md5sum {
-			logger.Fatalf("Incompatible message type!")
+			conn.Close()
+			return nil, fmt.Errorf("incompatible service type: %s", resHeaderMap["type"])
 		}
 		logger.Debug("Start receiving messages...")
 	}
+	return conn, nil
+}
+
+// call sends the request on the connection and reads the response.
+func (c *defaultServiceClient) call(ctx context.Context, conn net.Conn, srv Service) error {
+	logger := c.logger
+	defer watchContext(ctx, conn)()
 
 	// 3. Send request
 	var buf bytes.Buffer
 	_ = srv.ReqMessage().Serialize(&buf)
 	reqMsg := buf.Bytes()
 	size := uint32(len(reqMsg))
-	conn.SetDeadline(time.Now().Add(c.tcpTimeout))
+	conn.SetDeadline(c.deadline(ctx))
 	if err := binary.Write(conn, binary.LittleEndian, size); err != nil {
 		return err
 	}
 	logger.Debug(len(reqMsg))
-	conn.SetDeadline(time.Now().Add(c.tcpTimeout))
+	conn.SetDeadline(c.deadline(ctx))
 	if _, err := conn.Write(reqMsg); err != nil {
 		return err
 	}
 
 	// 4. Read OK byte
 	var ok byte
-	conn.SetDeadline(time.Now().Add(c.tcpTimeout))
+	conn.SetDeadline(c.deadline(ctx))
 	if err := binary.Read(conn, binary.LittleEndian, &ok); err != nil {
 		return err
 	} else {
 		if ok == 0 {
 			var size uint32
-			conn.SetDeadline(time.Now().Add(c.tcpTimeout))
+			conn.SetDeadline(c.deadline(ctx))
 			if err := binary.Read(conn, binary.LittleEndian, &size); err != nil {
 				return err
 			}
 			errMsg := make([]byte, int(size))
-			conn.SetDeadline(time.Now().Add(c.tcpTimeout))
+			conn.SetDeadline(c.deadline(ctx))
 			if _, err := io.ReadFull(conn, errMsg); err != nil {
 				return err
 			} else {
-				return errors.New(string(errMsg))
+				return serviceError(errMsg)
 			}
 		}
 	}
 
 	// 5. Receive response
-	conn.SetDeadline(time.Now().Add(c.tcpTimeout))
+	conn.SetDeadline(c.deadline(ctx))
 	//logger.Debug("Reading message size...")
 	var msgSize uint32
 	if err := binary.Read(conn, binary.LittleEndian, &msgSize); err != nil {
@@ -140,7 +242,7 @@ func (c *defaultServiceClient) Call(srv Service) error {
 	logger.Debugf("  %d", msgSize)
 	resBuffer := make([]byte, int(msgSize))
 	//logger.Debug("Reading message body...")
-	if _, err = io.ReadFull(conn, resBuffer); err != nil {
+	if _, err := io.ReadFull(conn, resBuffer); err != nil {
 		return err
 	}
 	resReader := NewReader(resBuffer)
@@ -150,4 +252,39 @@ func (c *defaultServiceClient) Call(srv Service) error {
 	return nil
 }
 
-func (*defaultServiceClient) Shutdown() {}
+// deadline returns the deadline of the next TCP operation.
+func (c *defaultServiceClient) deadline(ctx context.Context) time.Time {
+	deadline := time.Now().Add(c.tcpTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		return d
+	}
+	return deadline
+}
+
+// watchContext closes conn if ctx is done before the returned function is called.
+func watchContext(ctx context.Context, conn net.Conn) func() {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+	}
+}
+
+// Shutdown closes the connection of a persistent client.
+func (c *defaultServiceClient) Shutdown() {
+	c.connMutex.Lock()
+	defer c.connMutex.Unlock()
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"container/list"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -23,6 +24,7 @@ type remoteClientSessionCloseEvent struct {
 
 type defaultServiceServer struct {
 	node             *defaultNode
+	logger           Logger
 	service          string
 	srvType          ServiceType
 	handler          interface{}
@@ -32,25 +34,26 @@ type defaultServiceServer struct {
 	shutdownChan     chan struct{}
 	sessionCloseChan chan *remoteClientSessionCloseEvent
 	tcpTimeout       time.Duration
+	handlerTimeout   time.Duration
+	concurrent       bool
+	workers          chan struct{} // Limits the number of concurrent handlers, nil if unlimited.
 }
 
-func newDefaultServiceServer(node *defaultNode, service string, srvType ServiceType, handler interface{}, opts ...ServiceServerOption) *defaultServiceServer {
-	logger := node.logger
+func newDefaultServiceServer(node *defaultNode, service string, srvType ServiceType, handler interface{}, opts ...ServiceServerOption) (*defaultServiceServer, error) {
+	logger := node.logger.child("service")
 	server := new(defaultServiceServer)
-	if listener, err := net.Listen("tcp", ":0"); err != nil {
-		panic(err)
-	} else {
-		if tcpListener, ok := listener.(*net.TCPListener); ok {
-			server.listener = tcpListener
-		} else {
-			panic(fmt.Errorf("Server listener is not TCPListener"))
-		}
+	listener, err := net.ListenTCP("tcp", &net.TCPAddr{})
+	if err != nil {
+		return nil, err
 	}
+	server.listener = listener
 	server.node = node
+	server.logger = logger
 	server.service = service
 	server.srvType = srvType
 	server.handler = handler
 	server.tcpTimeout = 10 * time.Millisecond
+	server.handlerTimeout = 1000 * time.Millisecond
 	for _, option := range opts {
 		option(server)
 	}
@@ -71,12 +74,42 @@ func newDefaultServiceServer(node *defaultNode, service string, srvType ServiceT
 		server.rosrpcAddr,
 		node.xmlrpcURI)
 	if err != nil {
-		logger.Errorf("Failed to register service %s", service)
 		server.listener.Close()
-		return nil
+		return nil, fmt.Errorf("failed to register service %s: %v", service, err)
 	}
 	go server.start()
-	return server
+	return server, nil
+}
+
+// execute runs a handler job in the node's spinner, or in its own goroutine if the server is
+// concurrent. A job still waiting for the spinner or a worker is skipped once abandoned is closed.
+func (s *defaultServiceServer) execute(abandoned chan struct{}, job func()) {
+	run := func() {
+		select {
+		case <-abandoned:
+			return
+		default:
+			job()
+		}
+	}
+	go func() {
+		if !s.concurrent {
+			select {
+			case s.node.jobChan <- run:
+			case <-abandoned:
+			}
+			return
+		}
+		if s.workers != nil {
+			select {
+			case s.workers <- struct{}{}:
+				defer func() { <-s.workers }()
+			case <-abandoned:
+				return
+			}
+		}
+		run()
+	}()
 }
 
 func (s *defaultServiceServer) Shutdown() {
@@ -85,7 +118,7 @@ func (s *defaultServiceServer) Shutdown() {
 
 // event loop
 func (s *defaultServiceServer) start() {
-	logger := s.node.logger
+	logger := s.logger
 	logger.Debugf("service server '%s' start listen %s.", s.service, s.listener.Addr().String())
 	s.node.waitGroup.Add(1)
 	defer func() {
@@ -113,7 +146,7 @@ func (s *defaultServiceServer) start() {
 		select {
 		case ev := <-s.sessionCloseChan:
 			if ev.err != nil {
-				logger.Error("session error: %v", ev.err)
+				logger.Errorf("session error: %v", ev.err)
 			}
 			for e := s.sessions.Front(); e != nil; e = e.Next() {
 				if e.Value == ev.session {
@@ -129,7 +162,7 @@ func (s *defaultServiceServer) start() {
 			_, err := callRosAPI(s.node.masterURI, "unregisterService",
 				s.node.qualifiedName, s.service, s.rosrpcAddr)
 			if err != nil {
-				logger.Warn("Failed unregisterService(%s): %v", s.service, err)
+				logger.Warnf("Failed unregisterService(%s): %v", s.service, err)
 			}
 			logger.Debugf("Called unregisterService(%s)", s.service)
 			for e := s.sessions.Front(); e != nil; e = e.Next() {
@@ -146,12 +179,10 @@ func (s *defaultServiceServer) start() {
 }
 
 type remoteClientSession struct {
-	server       *defaultServiceServer
-	conn         net.Conn
-	quitChan     chan struct{}
-	responseChan chan []byte
-	errorChan    chan error
-	tcpTimeout   time.Duration
+	server     *defaultServiceServer
+	conn       net.Conn
+	quitChan   chan struct{}
+	tcpTimeout time.Duration
 }
 
 func newRemoteClientSession(s *defaultServiceServer, conn net.Conn) *remoteClientSession {
@@ -159,23 +190,23 @@ func newRemoteClientSession(s *defaultServiceServer, conn net.Conn) *remoteClien
 	session.server = s
 	session.conn = conn
 	session.quitChan = make(chan struct{}, 1)
-	session.responseChan = make(chan []byte)
-	session.errorChan = make(chan error)
 	session.tcpTimeout = s.tcpTimeout
 	return session
 }
 
+var errSessionQuit = errors.New("service session quit")
+
 func (s *remoteClientSession) start() {
-	logger := s.server.node.logger
+	logger := s.server.logger
 	conn := s.conn
 	nodeID := s.server.node.qualifiedName
 	service := s.server.service
 	md5sum := s.server.srvType.MD5Sum()
 	srvType := s.server.srvType.Name()
-	var err error
 	logger.Debugf("remoteClientSession.start '%s'", s.server.service)
 	defer func() {
 		logger.Debug("remoteClientSession.start exit")
+		conn.Close()
 	}()
 	defer func() {
 		if err := recover(); err != nil {
@@ -224,30 +255,98 @@ func (s *remoteClientSession) start() {
 	}
 	if reqHeaderMap["service"] != service ||
 		reqHeaderMap["md5sum"] != md5sum {
-		logger.Fatalf("Incompatible message type!")
+		panic(fmt.Errorf("incompatible service type from %s", reqHeaderMap["callerid"]))
 	}
 
-	// 3. Read request
+	// A persistent client sends any number of requests on the connection.
+	persistent := reqHeaderMap["persistent"] == "1"
+	for {
+		// 3. Read request
+		var reqBuffer []byte
+		if persistent {
+			reqBuffer, err = s.waitRequest()
+			if err == io.EOF || err == errSessionQuit {
+				logger.Debug("Persistent service session closed")
+				return
+			}
+		} else {
+			reqBuffer, err = s.readRequest()
+		}
+		if err != nil {
+			panic(err)
+		}
+
+		if !s.handleRequest(reqBuffer) || !persistent {
+			return
+		}
+	}
+}
+
+func (s *remoteClientSession) readRequest() ([]byte, error) {
+	logger := s.server.logger
+	conn := s.conn
 	logger.Debug("Reading message size...")
 	var msgSize uint32
 	conn.SetDeadline(time.Now().Add(s.tcpTimeout))
 	if err := binary.Read(conn, binary.LittleEndian, &msgSize); err != nil {
-		panic(err)
+		return nil, err
 	}
 	logger.Debugf("  %d", msgSize)
-	resBuffer := make([]byte, int(msgSize))
+	reqBuffer := make([]byte, int(msgSize))
 	logger.Debug("Reading message body...")
 	conn.SetDeadline(time.Now().Add(s.tcpTimeout))
-	if _, err = io.ReadFull(conn, resBuffer); err != nil {
-		panic(err)
+	if _, err := io.ReadFull(conn, reqBuffer); err != nil {
+		return nil, err
 	}
+	return reqBuffer, nil
+}
 
-	s.server.node.jobChan <- func() {
+// waitRequest waits for the next request on a persistent connection. It returns io.EOF when
+// the client closed the connection and errSessionQuit when the server shuts down.
+func (s *remoteClientSession) waitRequest() ([]byte, error) {
+	var sizeBytes [4]byte
+	n := 0
+	for n < len(sizeBytes) {
+		select {
+		case <-s.quitChan:
+			return nil, errSessionQuit
+		default:
+		}
+		s.conn.SetDeadline(time.Now().Add(100 * time.Millisecond))
+		m, err := s.conn.Read(sizeBytes[n:])
+		n += m
+		if err != nil {
+			if neterr, ok := err.(net.Error); ok && neterr.Timeout() {
+				continue
+			}
+			return nil, err
+		}
+	}
+	msgSize := binary.LittleEndian.Uint32(sizeBytes[:])
+	reqBuffer := make([]byte, int(msgSize))
+	s.conn.SetDeadline(time.Now().Add(s.tcpTimeout))
+	if _, err := io.ReadFull(s.conn, reqBuffer); err != nil {
+		return nil, err
+	}
+	return reqBuffer, nil
+}
+
+// handleRequest runs the handler and writes the response. It returns false if the server
+// is shutting down.
+func (s *remoteClientSession) handleRequest(reqBuffer []byte) bool {
+	logger := s.server.logger
+	// Buffered, so that a handler finishing after the timeout doesn't block.
+	responseChan := make(chan []byte, 1)
+	errorChan := make(chan error, 1)
+	abandoned := make(chan struct{})
+	defer close(abandoned)
+	s.server.execute(abandoned, func() {
 		srv := s.server.srvType.NewService()
-		reader := NewReader(resBuffer)
+		reader := NewReader(reqBuffer)
 		err := srv.ReqMessage().Deserialize(reader)
 		if err != nil {
-			s.errorChan <- err
+			errorChan <- err
+			return
 		}
 		args := []reflect.Value{reflect.ValueOf(srv)}
 		fun := reflect.ValueOf(s.server.handler)
@@ -255,7 +354,7 @@ func (s *remoteClientSession) start() {
 
 		if len(results) != 1 {
 			logger.Debug("Service callback return type must be 'error'")
-			s.errorChan <- err
+			errorChan <- fmt.Errorf("Service handler has invalid signature")
 			return
 		}
 		result := results[0]
@@ -263,58 +362,54 @@ func (s *remoteClientSession) start() {
 			logger.Debug("Service callback success")
 			var buf bytes.Buffer
 			_ = srv.ResMessage().Serialize(&buf)
-			s.responseChan <- buf.Bytes()
+			responseChan <- buf.Bytes()
 		} else {
 			logger.Debug("Service callback failure")
 			if err, ok := result.Interface().(error); ok {
-				s.errorChan <- err
+				errorChan <- err
 			} else {
-				s.errorChan <- fmt.Errorf("Service handler has invalid signature")
+				errorChan <- fmt.Errorf("Service handler has invalid signature")
 			}
 		}
-	}
+	})
 
-	timeoutChan := time.After(1000 * time.Millisecond)
+	// A nil channel never fires, so there is no timeout if it's disabled.
+	var timeoutChan <-chan time.Time
+	if s.server.handlerTimeout > 0 {
+		timeoutChan = time.After(s.server.handlerTimeout)
+	}
+	var ok byte
+	var resMsg []byte
 	select {
-	case resMsg := <-s.responseChan:
-		// 4. Write OK byte
-		var ok byte = 1
-		conn.SetDeadline(time.Now().Add(s.tcpTimeout))
-		if err := binary.Write(conn, binary.LittleEndian, &ok); err != nil {
-			panic(err)
-		}
-		// 5. Write response
-		logger.Debug(len(resMsg))
-		size := uint32(len(resMsg))
-		conn.SetDeadline(time.Now().Add(s.tcpTimeout))
-		if err := binary.Write(conn, binary.LittleEndian, size); err != nil {
-			panic(err)
-		}
-		conn.SetDeadline(time.Now().Add(s.tcpTimeout))
-		if _, err := conn.Write(resMsg); err != nil {
-			panic(err)
-		}
-	case err := <-s.errorChan:
+	case resMsg = <-responseChan:
+		ok = 1
+	case err := <-errorChan:
 		logger.Error(err)
-		// 4. Write OK byte
-		var ok byte
-		conn.SetDeadline(time.Now().Add(s.tcpTimeout))
-		if err := binary.Write(conn, binary.LittleEndian, &ok); err != nil {
-			panic(err)
-		}
-		errMsg := err.Error()
-		size := uint32(len(errMsg))
-		conn.SetDeadline(time.Now().Add(s.tcpTimeout))
-		if err := binary.Write(conn, binary.LittleEndian, size); err != nil {
-			panic(err)
-		}
-		conn.SetDeadline(time.Now().Add(s.tcpTimeout))
-		if _, err := conn.Write([]byte(errMsg)); err != nil {
-			panic(err)
-		}
+		resMsg = []byte(err.Error())
 	case <-s.quitChan:
-		s.errorChan <- fmt.Errorf("service shut down")
+		return false
 	case <-timeoutChan:
-		panic(fmt.Errorf("service callback timeout"))
+		logger.Errorf("Service %s callback timed out after %v", s.server.service, s.server.handlerTimeout)
+		resMsg = []byte("service callback timeout")
+	}
+
+	// 4. Write OK byte
+	conn := s.conn
+	conn.SetDeadline(time.Now().Add(s.tcpTimeout))
+	if err := binary.Write(conn, binary.LittleEndian, &ok); err != nil {
+		panic(err)
+	}
+	// 5. Write response or error message
+	logger.Debug(len(resMsg))
+	size := uint32(len(resMsg))
+	conn.SetDeadline(time.Now().Add(s.tcpTimeout))
+	if err := binary.Write(conn, binary.LittleEndian, size); err != nil {
+		panic(err)
+	}
+	conn.SetDeadline(time.Now().Add(s.tcpTimeout))
+	if _, err := conn.Write(resMsg); err != nil {
+		panic(err)
 	}
+	s.server.node.busStats.addServiceCall(4+len(reqBuffer), 5+len(resMsg))
+	return true
 }
@@ -0,0 +1,271 @@
+package ros
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// A hand written service echoing a string, with testMessage as request and response.
+type testServiceType struct{}
+
+func (t *testServiceType) MD5Sum() string {
+	return "c2d6a3ab8f4e1a96e1b2b6d9e0d0b2f1"
+}
+
+func (t *testServiceType) Name() string {
+	return "rosgo_test/Echo"
+}
+
+func (t *testServiceType) RequestType() MessageType {
+	return msgTestMessage
+}
+
+func (t *testServiceType) ResponseType() MessageType {
+	return msgTestMessage
+}
+
+func (t *testServiceType) NewService() Service {
+	return new(testService)
+}
+
+var srvTestService = &testServiceType{}
+
+type testService struct {
+	Request  testMessage
+	Response testMessage
+}
+
+func (s *testService) ReqMessage() Message {
+	return &s.Request
+}
+
+func (s *testService) ResMessage() Message {
+	return &s.Response
+}
+
+// spinNode spins the node in the background until the returned function is called.
+func spinNode(node Node) func() {
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-quit:
+				return
+			default:
+				node.SpinOnce()
+			}
+		}
+	}()
+	return func() {
+		close(quit)
+		<-done
+	}
+}
+
+func echoHandler(srv *testService) error {
+	if srv.Request.Data == "fail" {
+		return errors.New("failed")
+	}
+	srv.Response.Data = srv.Request.Data
+	return nil
+}
+
+func TestPersistentServiceClient(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	node := newTestNode(t, m, "/service_test")
+	defer node.Shutdown()
+	defer spinNode(node)()
+
+	server := node.NewServiceServer("/echo", srvTestService, echoHandler)
+	client := node.NewServiceClient("/echo", srvTestService,
+		ServiceClientPersistent(), ServiceClientTCPTimeout(time.Second)).(*defaultServiceClient)
+	defer client.Shutdown()
+
+	var conn interface{}
+	for i, data := range []string{"a", "fail", "b"} {
+		srv := &testService{Request: testMessage{data}}
+		err := client.Call(srv)
+		if data == "fail" {
+			if err == nil || err.Error() != "failed" {
+				t.Error(err)
+			}
+		} else if err != nil || srv.Response.Data != data {
+			t.Error(err, srv.Response)
+		}
+		if i == 0 {
+			conn = client.conn
+		} else if client.conn != conn {
+			t.Error("connection was not reused")
+		}
+	}
+
+	// Restart the server, the client must connect to the new one.
+	server.Shutdown()
+	node.NewServiceServer("/echo", srvTestService, echoHandler)
+	// Let the old server close its sessions.
+	time.Sleep(500 * time.Millisecond)
+	srv := &testService{Request: testMessage{"c"}}
+	if err := client.Call(srv); err != nil || srv.Response.Data != "c" {
+		t.Error(err, srv.Response)
+	}
+	if client.conn == conn {
+		t.Error("client did not reconnect")
+	}
+}
+
+func TestServiceCallContext(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	node := newTestNode(t, m, "/service_test")
+	defer node.Shutdown()
+
+	// Without spinning, the handler never runs.
+	node.NewServiceServer("/echo", srvTestService, echoHandler)
+	client := node.NewServiceClient("/echo", srvTestService, ServiceClientTCPTimeout(10*time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := client.CallContext(ctx, &testService{})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Error("call was not aborted by the deadline", elapsed)
+	}
+	if e, ok := err.(*contextError); !ok || e.Unwrap() != context.DeadlineExceeded {
+		t.Error(err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if err := client.CallContext(ctx, &testService{}); err != context.Canceled {
+		if e, ok := err.(*contextError); !ok || e.Unwrap() != context.Canceled {
+			t.Error(err)
+		}
+	}
+}
+
+func TestConcurrentServiceServer(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	node := newTestNode(t, m, "/service_test")
+	defer node.Shutdown()
+
+	// The node doesn't spin, handlers run in their own goroutines.
+	slowHandler := func(srv *testService) error {
+		d, _ := time.ParseDuration(srv.Request.Data)
+		time.Sleep(d)
+		srv.Response.Data = srv.Request.Data
+		return nil
+	}
+	node.NewServiceServer("/slow", srvTestService, slowHandler,
+		ServiceServerConcurrent(0), ServiceServerHandlerTimeout(0))
+	node.NewServiceServer("/limited", srvTestService, slowHandler,
+		ServiceServerConcurrent(1), ServiceServerHandlerTimeout(300*time.Millisecond))
+
+	call := func(service string, data string) error {
+		client := node.NewServiceClient(service, srvTestService, ServiceClientTCPTimeout(5*time.Second))
+		srv := &testService{Request: testMessage{data}}
+		err := client.Call(srv)
+		if err == nil && srv.Response.Data != data {
+			t.Error(srv.Response)
+		}
+		return err
+	}
+
+	start := time.Now()
+	errs := make(chan error)
+	for i := 0; i < 3; i++ {
+		go func() { errs <- call("/slow", "300ms") }()
+	}
+	for i := 0; i < 3; i++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 800*time.Millisecond {
+		t.Error("handlers did not run concurrently", elapsed)
+	}
+
+	// No timeout, although the handler runs longer than the default timeout.
+	if err := call("/slow", "1100ms"); err != nil {
+		t.Error(err)
+	}
+
+	// The second call waits for the worker and times out.
+	go func() { errs <- call("/limited", "200ms") }()
+	time.Sleep(20 * time.Millisecond)
+	if err := call("/limited", "200ms"); err == nil || err.Error() != "service callback timeout" {
+		t.Error(err)
+	}
+	if err := <-errs; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAbandonedServiceRequest(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	node := newTestNode(t, m, "/service_test")
+	defer node.Shutdown()
+
+	called := 0
+	node.NewServiceServer("/count", srvTestService, func(srv *testService) error {
+		called++
+		return nil
+	}, ServiceServerHandlerTimeout(100*time.Millisecond))
+	client := node.NewServiceClient("/count", srvTestService, ServiceClientTCPTimeout(5*time.Second))
+
+	// The node doesn't spin, the request times out even with a full callback queue.
+	for i := 0; i < cap(node.jobChan); i++ {
+		node.jobChan <- func() {}
+	}
+	if err := client.Call(&testService{}); err == nil || err.Error() != "service callback timeout" {
+		t.Error(err)
+	}
+	for len(node.jobChan) > 0 {
+		node.SpinOnce()
+	}
+	// The job queued for this request is skipped when the node spins after the timeout.
+	if err := client.Call(&testService{}); err == nil || err.Error() != "service callback timeout" {
+		t.Error(err)
+	}
+	for len(node.jobChan) > 0 {
+		node.SpinOnce()
+	}
+	if called != 0 {
+		t.Error("abandoned requests must not reach the handler", called)
+	}
+}
+
+// mismatchServiceType is rosgo_test/Echo with another md5sum.
+type mismatchServiceType struct {
+	testServiceType
+}
+
+func (t *mismatchServiceType) MD5Sum() string {
+	return "00000000000000000000000000000000"
+}
+
+func TestServiceTypeMismatch(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	node := newTestNode(t, m, "/mismatch_test")
+	defer node.Shutdown()
+	defer spinNode(node)()
+
+	node.NewServiceServer("/echo", srvTestService, echoHandler)
+	client := node.NewServiceClient("/echo", &mismatchServiceType{}, ServiceClientTCPTimeout(time.Second))
+	if err := client.Call(&testService{Request: testMessage{"a"}}); err == nil {
+		t.Error("call with another service type must fail")
+	}
+	// The server still serves compatible clients.
+	client = node.NewServiceClient("/echo", srvTestService, ServiceClientTCPTimeout(time.Second))
+	srv := &testService{Request: testMessage{"a"}}
+	if err := client.Call(srv); err != nil || srv.Response.Data != "a" {
+		t.Error(srv.Response, err)
+	}
+}
@@ -0,0 +1,103 @@
+package ros
+
+import (
+	"sync"
+)
+
+// CallbackQueue holds the callbacks waiting to be run by a spinner. Subscribers use the
+// node's global queue, which Spin and SpinOnce serve, unless SubscriberCallbackQueue is given.
+type CallbackQueue struct {
+	jobChan chan func()
+}
+
+// NewCallbackQueue creates a callback queue to be served by an AsyncSpinner.
+func NewCallbackQueue() *CallbackQueue {
+	return &CallbackQueue{make(chan func(), 100)}
+}
+
+// Spinner runs callbacks in background goroutines between Start and Stop.
+type Spinner interface {
+	// Start starts the workers. Calling Start on a running spinner has no effect.
+	Start()
+	// Stop waits for the running callbacks to return and stops the workers.
+	Stop()
+}
+
+type asyncSpinner struct {
+	jobChan  chan func()
+	workers  int
+	track    func(running bool) // Called when the spinner starts and stops, may be nil.
+	mutex    sync.Mutex
+	quitChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+func newAsyncSpinner(jobChan chan func(), workers int) *asyncSpinner {
+	if workers < 1 {
+		workers = 1
+	}
+	return &asyncSpinner{jobChan: jobChan, workers: workers}
+}
+
+func (s *asyncSpinner) Start() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.quitChan != nil {
+		return
+	}
+	s.quitChan = make(chan struct{})
+	if s.track != nil {
+		s.track(true)
+	}
+	for i := 0; i < s.workers; i++ {
+		s.wg.Add(1)
+		go s.run(s.quitChan)
+	}
+}
+
+func (s *asyncSpinner) run(quitChan chan struct{}) {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-quitChan:
+			return
+		case job := <-s.jobChan:
+			job()
+		}
+	}
+}
+
+func (s *asyncSpinner) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.quitChan == nil {
+		return
+	}
+	close(s.quitChan)
+	s.wg.Wait()
+	s.quitChan = nil
+	if s.track != nil {
+		s.track(false)
+	}
+}
+
+// NewAsyncSpinner creates a spinner with the given number of workers, serving queue or the
+// node's global callback queue if queue is nil. The node stops its spinners on Shutdown.
+func (node *defaultNode) NewAsyncSpinner(workers int, queue *CallbackQueue) Spinner {
+	jobChan := node.jobChan
+	if queue != nil {
+		jobChan = queue.jobChan
+	}
+	spinner := newAsyncSpinner(jobChan, workers)
+	// The node keeps the running spinners, to stop them on Shutdown.
+	spinner.track = func(running bool) {
+		node.spinnersMutex.Lock()
+		defer node.spinnersMutex.Unlock()
+		if running {
+			node.spinners[spinner] = struct{}{}
+		} else {
+			delete(node.spinners, spinner)
+		}
+	}
+	return spinner
+}
@@ -0,0 +1,89 @@
+package ros
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAsyncSpinner(t *testing.T) {
+	spinner := newAsyncSpinner(make(chan func(), 10), 2)
+	var running, maxRunning int32
+	job := func() {
+		n := atomic.AddInt32(&running, 1)
+		if n > atomic.LoadInt32(&maxRunning) {
+			atomic.StoreInt32(&maxRunning, n)
+		}
+		time.Sleep(50 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+	}
+	for i := 0; i < 4; i++ {
+		spinner.jobChan <- job
+	}
+	spinner.Start()
+	spinner.Start()
+	time.Sleep(150 * time.Millisecond)
+	spinner.Stop()
+	if n := len(spinner.jobChan); n != 0 {
+		t.Error("jobs left", n)
+	}
+	if maxRunning != 2 {
+		t.Error("jobs must run in 2 workers", maxRunning)
+	}
+
+	// A stopped spinner doesn't run jobs.
+	spinner.jobChan <- job
+	time.Sleep(20 * time.Millisecond)
+	if len(spinner.jobChan) != 1 {
+		t.Error("stopped spinner ran a job")
+	}
+}
+
+func TestDedicatedSpinner(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	node := newTestNode(t, m, "/spinner_test")
+	defer node.Shutdown()
+
+	slowQueue := NewCallbackQueue()
+	node.NewAsyncSpinner(1, slowQueue).Start()
+	spinner := node.NewAsyncSpinner(1, nil)
+	spinner.Start()
+
+	slowPub := node.NewPublisher("/slow", msgTestMessage)
+	fastPub := node.NewPublisher("/fast", msgTestMessage)
+	var slow, fast int32
+	node.NewSubscriber("/slow", msgTestMessage, func(msg *testMessage) {
+		time.Sleep(time.Second)
+		atomic.AddInt32(&slow, 1)
+	}, SubscriberCallbackQueue(slowQueue))
+	node.NewSubscriber("/fast", msgTestMessage, func(msg *testMessage) {
+		atomic.AddInt32(&fast, 1)
+	})
+	// Let the subscribers connect.
+	time.Sleep(500 * time.Millisecond)
+
+	slowPub.Publish(&testMessage{"slow"})
+	time.Sleep(50 * time.Millisecond)
+	for i := 0; i < 5; i++ {
+		fastPub.Publish(&testMessage{"fast"})
+	}
+	deadline := time.Now().Add(500 * time.Millisecond)
+	for atomic.LoadInt32(&fast) < 5 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&fast); n != 5 {
+		t.Error("fast callbacks were starved", n)
+	}
+	if n := atomic.LoadInt32(&slow); n != 0 {
+		t.Error(n)
+	}
+
+	// The node forgets stopped spinners.
+	spinner.Stop()
+	node.spinnersMutex.Lock()
+	if len(node.spinners) != 1 {
+		t.Error("node holds", len(node.spinners), "spinners")
+	}
+	node.spinnersMutex.Unlock()
+}
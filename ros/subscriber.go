@@ -28,9 +28,19 @@ type defaultSubscriber struct {
 	shutdownChan     chan struct{}
 	connections      map[string]chan struct{}
 	disconnectedChan chan string
+	queue            *messageQueue
+	queueSize        int
+	queuePolicy      QueuePolicy
+	transports       []string
+	maxDatagramSize  int
+	hostname         string
+	listenIP         string
+	busStats         *busStats
+	callbackQueue    *CallbackQueue
+	callbackMutex    sync.Mutex // Serializes the callbacks when several spinner workers run them.
 }
 
-func newDefaultSubscriber(topic string, msgType MessageType, callback interface{}) *defaultSubscriber {
+func newDefaultSubscriber(topic string, msgType MessageType, callback interface{}, options ...SubscriberOption) *defaultSubscriber {
 	sub := new(defaultSubscriber)
 	sub.topic = topic
 	sub.msgType = msgType
@@ -41,12 +51,19 @@ func newDefaultSubscriber(topic string, msgType MessageType, callback interface{
 	sub.disconnectedChan = make(chan string, 10)
 	sub.connections = make(map[string]chan struct{})
 	sub.callbacks = []interface{}{callback}
+	sub.queueSize = 100
+	sub.queuePolicy = QueueBlock
+	sub.transports = []string{TransportTCPROS}
+	sub.maxDatagramSize = DefaultMaxDatagramSize
+	for _, option := range options {
+		option(sub)
+	}
+	sub.queue = newMessageQueue(sub.queueSize, sub.queuePolicy)
 	return sub
 }
 
 func (sub *defaultSubscriber) start(wg *sync.WaitGroup, nodeID string, nodeURI string, masterURI string, jobChan chan func(), logger Logger, unregisterFromNode func()) {
 	logger.Debugf("Subscriber goroutine for %s started.", sub.topic)
-	wg.Add(1)
 	defer wg.Done()
 	defer func() {
 		logger.Debug("defaultSubscriber.start exit")
@@ -61,41 +78,15 @@ func (sub *defaultSubscriber) start(wg *sync.WaitGroup, nodeID string, nodeURI s
 			sub.pubList = list
 
 			for _, pub := range deadPubs {
-				quitChan := sub.connections[pub]
-				quitChan <- struct{}{}
-				delete(sub.connections, pub)
+				// Publishers which failed to connect, or already disconnected, have no connection.
+				if quitChan, ok := sub.connections[pub]; ok {
+					quitChan <- struct{}{}
+					delete(sub.connections, pub)
+				}
 			}
 
 			for _, pub := range newPubs {
-				protocols := []interface{}{[]interface{}{"TCPROS"}}
-				result, err := callRosAPI(pub, "requestTopic", nodeID, sub.topic, protocols)
-				if err != nil {
-					logger.Fatalf("[DefaultSubscriber] %v", err)
-					continue
-				}
-
-				protocolParams := result.([]interface{})
-				for _, x := range protocolParams {
-					logger.Debug(x)
-				}
-
-				name := protocolParams[0].(string)
-				if name == "TCPROS" {
-					addr := protocolParams[1].(string)
-					port := protocolParams[2].(int32)
-					uri := fmt.Sprintf("%s:%d", addr, port)
-					quitChan := make(chan struct{}, 10)
-					sub.connections[pub] = quitChan
-					go startRemotePublisherConn(logger,
-						uri, sub.topic,
-						sub.msgType.MD5Sum(),
-						sub.msgType.Name(), nodeID,
-						sub.msgChan,
-						quitChan,
-						sub.disconnectedChan)
-				} else {
-					logger.Warnf("rosgo Not support protocol '%s'", name)
-				}
+				sub.connectPublisher(pub, nodeID, logger)
 			}
 
 		case callback := <-sub.addCallbackChan:
@@ -103,16 +94,30 @@ func (sub *defaultSubscriber) start(wg *sync.WaitGroup, nodeID string, nodeURI s
 			sub.callbacks = append(sub.callbacks, callback)
 
 		case msgEvent := <-sub.msgChan:
-			// Pop received message then bind callbacks and enqueue to the job channle.
+			// Push received message to the queue then bind callbacks and enqueue to the job channle.
+			// Each job pops one message, so no job is added when the queue dropped a message.
 			logger.Debug("Receive msgChan")
+			if !sub.queue.push(msgEvent) {
+				logger.Debug("Subscriber queue is full, a message was dropped.")
+				continue
+			}
 			callbacks := make([]interface{}, len(sub.callbacks))
 			copy(callbacks, sub.callbacks)
 			jobChan <- func() {
+				sub.callbackMutex.Lock()
+				defer sub.callbackMutex.Unlock()
+				msgEvent, ok := sub.queue.pop()
+				if !ok {
+					return
+				}
 				m := sub.msgType.NewMessage()
 				reader := NewReader(msgEvent.bytes)
 				if err := m.Deserialize(reader); err != nil {
 					logger.Error(err)
 				}
+				if raw, ok := m.(*RawMessage); ok {
+					raw.setConnectionHeader(msgEvent.event.ConnectionHeader)
+				}
 				args := []reflect.Value{reflect.ValueOf(m), reflect.ValueOf(msgEvent.event)}
 				for _, callback := range callbacks {
 					fun := reflect.ValueOf(callback)
@@ -146,9 +151,135 @@ func (sub *defaultSubscriber) start(wg *sync.WaitGroup, nodeID string, nodeURI s
 	}
 }
 
+// connectPublisher negotiates a transport with the publisher and starts receiving messages.
+func (sub *defaultSubscriber) connectPublisher(pub string, nodeID string, logger Logger) {
+	var udpConn *net.UDPConn
+	var protocols []interface{}
+	for _, transport := range sub.transports {
+		switch transport {
+		case TransportTCPROS:
+			protocols = append(protocols, []interface{}{TransportTCPROS})
+		case TransportUDPROS:
+			if udpConn == nil {
+				addr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(sub.listenIP, "0"))
+				if err == nil {
+					udpConn, err = net.ListenUDP("udp", addr)
+				}
+				if err != nil {
+					logger.Warnf("Failed to open a UDPROS socket: %v", err)
+					continue
+				}
+			}
+			var headers []header
+			headers = append(headers, header{"topic", sub.topic})
+			headers = append(headers, header{"md5sum", sub.msgType.MD5Sum()})
+			headers = append(headers, header{"type", sub.msgType.Name()})
+			headers = append(headers, header{"callerid", nodeID})
+			port := udpConn.LocalAddr().(*net.UDPAddr).Port
+			protocols = append(protocols, []interface{}{
+				TransportUDPROS, encodeHeaderFields(headers), sub.hostname, port, sub.maxDatagramSize})
+		default:
+			logger.Warnf("rosgo Not support protocol '%s'", transport)
+		}
+	}
+
+	result, err := callRosAPI(pub, "requestTopic", nodeID, sub.topic, protocols)
+	if err != nil {
+		logger.Errorf("[DefaultSubscriber] %v", err)
+		if udpConn != nil {
+			udpConn.Close()
+		}
+		return
+	}
+
+	protocolParams, ok := result.([]interface{})
+	var name string
+	if ok && len(protocolParams) > 0 {
+		name, ok = protocolParams[0].(string)
+	}
+	if !ok {
+		logger.Errorf("Malformed requestTopic response from %s: %v", pub, result)
+		if udpConn != nil {
+			udpConn.Close()
+		}
+		return
+	}
+	for _, x := range protocolParams {
+		logger.Debug(x)
+	}
+
+	if name == TransportTCPROS {
+		if udpConn != nil {
+			udpConn.Close()
+		}
+		if len(protocolParams) < 3 {
+			logger.Errorf("Malformed TCPROS parameters from %s: %v", pub, protocolParams)
+			return
+		}
+		addr, ok1 := protocolParams[1].(string)
+		port, ok2 := protocolParams[2].(int32)
+		if !ok1 || !ok2 {
+			logger.Errorf("Malformed TCPROS parameters from %s: %v", pub, protocolParams)
+			return
+		}
+		uri := fmt.Sprintf("%s:%d", addr, port)
+		quitChan := make(chan struct{}, 10)
+		sub.connections[pub] = quitChan
+		stats := sub.busStats.open(sub.busStats.nextConnectionID(), pub, connectionInbound, TransportTCPROS, sub.topic)
+		go startRemotePublisherConn(logger,
+			uri, sub.topic,
+			sub.msgType.MD5Sum(),
+			sub.msgType.Name(), nodeID,
+			stats,
+			sub.msgChan,
+			quitChan,
+			sub.disconnectedChan)
+	} else if name == TransportUDPROS && udpConn != nil && len(protocolParams) == 6 {
+		connectionID, ok1 := protocolParams[3].(int32)
+		maxDatagramSize, ok2 := protocolParams[4].(int32)
+		headerBytes, ok3 := protocolParams[5].([]byte)
+		if !ok1 || !ok2 || !ok3 {
+			logger.Errorf("Malformed UDPROS parameters from %s: %v", pub, protocolParams)
+			udpConn.Close()
+			return
+		}
+		headers, err := decodeHeaderFields(headerBytes)
+		if err != nil {
+			logger.Errorf("Invalid UDPROS header from %s: %v", pub, err)
+			udpConn.Close()
+			return
+		}
+		resHeaderMap := make(map[string]string)
+		for _, h := range headers {
+			resHeaderMap[h.key] = h.value
+		}
+		if resHeaderMap["md5sum"] != sub.msgType.MD5Sum() && sub.msgType.MD5Sum() != "*" {
+			logger.Errorf("incompatible message type: md5sum mismatch with %s", pub)
+			udpConn.Close()
+			return
+		}
+		quitChan := make(chan struct{}, 10)
+		sub.connections[pub] = quitChan
+		stats := sub.busStats.open(sub.busStats.nextConnectionID(), pub, connectionInbound, TransportUDPROS, sub.topic)
+		go startRemotePublisherUDPConn(logger,
+			udpConn, uint32(connectionID), int(maxDatagramSize),
+			pub, sub.topic, resHeaderMap,
+			stats,
+			sub.msgChan,
+			quitChan,
+			sub.disconnectedChan)
+	} else {
+		logger.Warnf("rosgo Not support protocol '%s'", name)
+		if udpConn != nil {
+			udpConn.Close()
+		}
+	}
+}
+
 func startRemotePublisherConn(logger Logger,
 	pubURI string, topic string, md5sum string,
 	msgType string, nodeID string,
+	stats *connectionStats,
 	msgChan chan messageEvent,
 	quitChan chan struct{},
 	disconnectedChan chan string) {
@@ -156,12 +287,16 @@ func startRemotePublisherConn(logger Logger,
 
 	defer func() {
 		logger.Debug("startRemotePublisherConn() exit")
+		stats.close()
 	}()
 
 	conn, err := net.Dial("tcp", pubURI)
 	if err != nil {
-		logger.Fatalf("Failed to connect %s!", pubURI)
+		logger.Errorf("Failed to connect %s: %v", pubURI, err)
+		disconnectedChan <- pubURI
+		return
 	}
+	defer conn.Close()
 
 	// 1. Write connection header
 	var headers []header
@@ -175,14 +310,18 @@ func startRemotePublisherConn(logger Logger,
 	}
 	err = writeConnectionHeader(headers, conn)
 	if err != nil {
-		logger.Fatal("Failed to write connection header.")
+		logger.Errorf("Failed to write connection header to %s: %v", pubURI, err)
+		disconnectedChan <- pubURI
+		return
 	}
 
 	// 2. Read reponse header
 	var resHeaders []header
 	resHeaders, err = readConnectionHeader(conn)
 	if err != nil {
-		logger.Fatal("Failed to read reasponse header.")
+		logger.Errorf("Failed to read response header from %s: %v", pubURI, err)
+		disconnectedChan <- pubURI
+		return
 	}
 	logger.Debug("TCPROS Response Header:")
 	resHeaderMap := make(map[string]string)
@@ -192,7 +331,9 @@ func startRemotePublisherConn(logger Logger,
 	}
 
 	if md5sum != resHeaderMap["md5sum"] && md5sum != "*" {
-		panic("incompatible message type: md5sum mismatch")
+		logger.Errorf("Incompatible message type from %s: md5sum mismatch", pubURI)
+		disconnectedChan <- pubURI
+		return
 	}
 
 	logger.Debug("Start receiving messages...")
@@ -253,6 +394,7 @@ func startRemotePublisherConn(logger Logger,
 					}
 				}
 				event.ReceiptTime = time.Now()
+				stats.addMessage(4 + len(buffer))
 				msgChan <- messageEvent{bytes: buffer, event: event}
 				readingSize = true
 			}
@@ -261,6 +403,7 @@ func startRemotePublisherConn(logger Logger,
 }
 
 func (sub *defaultSubscriber) Shutdown() {
+	sub.queue.close()
 	sub.shutdownChan <- struct{}{}
 }
 
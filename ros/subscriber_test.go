@@ -0,0 +1,51 @@
+package ros
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fetchrobotics/rosgo/xmlrpc"
+)
+
+// A publisher whose requestTopic responses can't be used must neither crash the subscriber
+// nor block it when the publisher goes away.
+func TestMalformedRequestTopicResponse(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	node := newTestNode(t, m, "/malformed_publisher_test")
+
+	responses := []interface{}{
+		[]interface{}{},
+		[]interface{}{TransportTCPROS},
+		[]interface{}{TransportTCPROS, "localhost", "port"},
+		[]interface{}{int32(1)},
+		"TCPROS",
+	}
+	var publishers []interface{}
+	for _, r := range responses {
+		response := r
+		server := httptest.NewServer(xmlrpc.NewHandler(map[string]xmlrpc.Method{
+			"requestTopic": func(callerID string, topic string, protocols []interface{}) (interface{}, error) {
+				return buildRosAPIResult(APIStatusSuccess, "Success", response), nil
+			},
+		}))
+		defer server.Close()
+		publishers = append(publishers, server.URL)
+	}
+
+	node.NewSubscriber("/malformed", msgTestMessage, func(msg *testMessage) {})
+	node.publisherUpdate("/master", "/malformed", publishers)
+	node.publisherUpdate("/master", "/malformed", []interface{}{})
+
+	done := make(chan struct{})
+	go func() {
+		node.Shutdown()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown blocked")
+	}
+}
@@ -13,7 +13,19 @@ func NewTime(sec uint32, nsec uint32) Time {
 	return Time{temporal{sec, nsec}}
 }
 
+// Now returns the current ROS time, which is the simulated time published on /clock if the
+// parameter /use_sim_time was true when a node started.
 func Now() Time {
+	simTime.mutex.Lock()
+	enabled, now := simTime.enabled, simTime.now
+	simTime.mutex.Unlock()
+	if enabled {
+		return now
+	}
+	return wallNow()
+}
+
+func wallNow() Time {
 	var t Time
 	t.FromNSec(uint64(gotime.Now().UnixNano()))
 	return t
@@ -0,0 +1,211 @@
+package ros
+
+import (
+	"sync"
+)
+
+// TimerEvent is passed to timer callbacks.
+type TimerEvent struct {
+	// Expected and actual times of the previous call, zero for the first call.
+	LastExpected Time
+	LastReal     Time
+	// Expected and actual times of this call.
+	CurrentExpected Time
+	CurrentReal     Time
+	// Run time of the previous callback.
+	LastDuration Duration
+}
+
+// Timer calls a callback periodically, or once, through the node's callback queue.
+type Timer interface {
+	// Start starts a stopped timer. The first call is one period after Start.
+	Start()
+	// Stop stops the timer. A callback already queued is dropped.
+	Stop()
+	// SetPeriod changes the period. A running timer restarts, so the next call is one new
+	// period from now.
+	SetPeriod(period Duration)
+}
+
+// minTimerPeriod is the shortest period of a periodic timer, so that a zero period doesn't
+// busy-loop.
+var minTimerPeriod = NewDuration(0, 1000000)
+
+type defaultTimer struct {
+	jobChan  chan func()
+	callback func(TimerEvent)
+	oneshot  bool
+	clock    clock
+	track    func(running bool) // Called when the timer starts and stops, may be nil.
+
+	mutex        sync.Mutex
+	period       Duration
+	quitChan     chan struct{} // nil when the timer is stopped.
+	generation   int           // Incremented by Stop, so queued callbacks of a stopped timer are dropped.
+	pending      bool          // A callback is queued, don't queue another one.
+	lastExpected Time
+	lastReal     Time
+	lastDuration Duration
+}
+
+func newDefaultTimer(jobChan chan func(), period Duration, callback func(TimerEvent), oneshot bool, clock clock) *defaultTimer {
+	t := new(defaultTimer)
+	t.jobChan = jobChan
+	t.period = period
+	t.callback = callback
+	t.oneshot = oneshot
+	t.clock = clock
+	return t
+}
+
+func (t *defaultTimer) Start() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.startLocked()
+}
+
+func (t *defaultTimer) startLocked() {
+	if t.quitChan != nil {
+		return
+	}
+	t.quitChan = make(chan struct{})
+	if t.track != nil {
+		t.track(true)
+	}
+	now := t.clock.now()
+	go t.run(t.quitChan, t.generation, now.Add(t.period))
+}
+
+func (t *defaultTimer) Stop() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.stopLocked()
+}
+
+func (t *defaultTimer) stopLocked() {
+	if t.quitChan == nil {
+		return
+	}
+	close(t.quitChan)
+	t.quitChan = nil
+	t.generation++
+	t.pending = false
+	if t.track != nil {
+		t.track(false)
+	}
+}
+
+func (t *defaultTimer) SetPeriod(period Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	running := t.quitChan != nil
+	t.stopLocked()
+	t.period = period
+	if running {
+		t.startLocked()
+	}
+}
+
+func (t *defaultTimer) run(quitChan chan struct{}, generation int, next Time) {
+	for {
+		now := t.clock.now()
+		if next.Cmp(now) > 0 {
+			if !t.clock.waitUntil(next, quitChan) {
+				return
+			}
+			continue
+		}
+
+		t.mutex.Lock()
+		if t.generation != generation {
+			t.mutex.Unlock()
+			return
+		}
+		event := TimerEvent{
+			LastExpected:    t.lastExpected,
+			LastReal:        t.lastReal,
+			CurrentExpected: next,
+			CurrentReal:     now,
+			LastDuration:    t.lastDuration,
+		}
+		queue := !t.pending
+		if queue {
+			t.pending = true
+			t.lastExpected = next
+			t.lastReal = now
+		}
+		period := t.period
+		if !t.oneshot && period.Cmp(minTimerPeriod) < 0 {
+			period = minTimerPeriod
+		}
+		if t.oneshot {
+			t.quitChan = nil
+			if t.track != nil {
+				t.track(false)
+			}
+		}
+		t.mutex.Unlock()
+
+		if queue {
+			select {
+			case t.jobChan <- func() { t.call(generation, event) }:
+			case <-quitChan:
+				return
+			}
+		}
+		if t.oneshot {
+			return
+		}
+		next = next.Add(period)
+		if now = t.clock.now(); next.Cmp(now) < 0 {
+			// Fell behind by more than a period, skip the missed calls.
+			next = now.Add(period)
+		}
+	}
+}
+
+func (t *defaultTimer) call(generation int, event TimerEvent) {
+	t.mutex.Lock()
+	if t.generation != generation {
+		t.mutex.Unlock()
+		return
+	}
+	t.pending = false
+	t.mutex.Unlock()
+
+	start := wallNow()
+	t.callback(event)
+	end := wallNow()
+
+	t.mutex.Lock()
+	t.lastDuration = end.Diff(start)
+	t.mutex.Unlock()
+}
+
+// NewTimer creates a started timer calling callback every period, or once if oneshot is true.
+// Callbacks are run by the spinners of the node's global callback queue. The timer follows
+// the simulated time when it's enabled.
+func (node *defaultNode) NewTimer(period Duration, callback func(TimerEvent), oneshot bool) Timer {
+	return node.newTimer(period, callback, oneshot, rosClock{})
+}
+
+// NewWallTimer creates a timer like NewTimer, which always follows the wall clock.
+func (node *defaultNode) NewWallTimer(period Duration, callback func(TimerEvent), oneshot bool) Timer {
+	return node.newTimer(period, callback, oneshot, wallClock{})
+}
+
+func (node *defaultNode) newTimer(period Duration, callback func(TimerEvent), oneshot bool, clock clock) Timer {
+	timer := newDefaultTimer(node.jobChan, period, callback, oneshot, clock)
+	// The node keeps the running timers, to stop them on Shutdown.
+	timer.track = func(running bool) {
+		node.spinnersMutex.Lock()
+		defer node.spinnersMutex.Unlock()
+		if running {
+			node.timers[timer] = struct{}{}
+		} else {
+			delete(node.timers, timer)
+		}
+	}
+	timer.Start()
+	return timer
+}
@@ -0,0 +1,97 @@
+package ros
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimer(t *testing.T) {
+	jobChan := make(chan func(), 10)
+	var period Duration
+	period.FromSec(0.02)
+	var events []TimerEvent
+	timer := newDefaultTimer(jobChan, period, func(ev TimerEvent) {
+		events = append(events, ev)
+	}, false, wallClock{})
+	timer.Start()
+	for len(events) < 3 {
+		select {
+		case job := <-jobChan:
+			job()
+		case <-time.After(time.Second):
+			t.Fatal("timer didn't fire")
+		}
+	}
+	timer.Stop()
+
+	if !events[0].LastExpected.IsZero() {
+		t.Error("first event must have no last call", events[0])
+	}
+	for i := 1; i < len(events); i++ {
+		expected := events[i-1].CurrentExpected.Add(period)
+		if events[i].CurrentExpected != expected {
+			t.Error("expected times must be one period apart", events[i-1], events[i])
+		}
+		if events[i].LastExpected != events[i-1].CurrentExpected || events[i].LastReal != events[i-1].CurrentReal {
+			t.Error(events[i-1], events[i])
+		}
+		if events[i].CurrentReal.Cmp(events[i].CurrentExpected) < 0 {
+			t.Error("timer fired early", events[i])
+		}
+	}
+
+	// Stopped timer drops queued callbacks and doesn't fire.
+	time.Sleep(50 * time.Millisecond)
+	for len(jobChan) > 0 {
+		(<-jobChan)()
+	}
+	if len(events) != 3 {
+		t.Error(len(events))
+	}
+
+	// SetPeriod restarts a stopped timer only when started.
+	period.FromSec(0.01)
+	timer.SetPeriod(period)
+	timer.Start()
+	select {
+	case job := <-jobChan:
+		job()
+	case <-time.After(time.Second):
+		t.Fatal("restarted timer didn't fire")
+	}
+	timer.Stop()
+}
+
+func TestOneshotTimer(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	node := newTestNode(t, m, "/timer_test")
+	defer node.Shutdown()
+
+	var period Duration
+	period.FromSec(0.01)
+	count := 0
+	node.NewTimer(period, func(ev TimerEvent) {
+		count++
+	}, true)
+	spinUntil(node, 200*time.Millisecond, func() bool { return false })
+	if count != 1 {
+		t.Error("oneshot timer fired", count, "times")
+	}
+
+	// A zero period doesn't busy-loop, and the node forgets stopped timers.
+	count = 0
+	timer := node.NewTimer(Duration{}, func(ev TimerEvent) {
+		count++
+	}, false)
+	spinUntil(node, 100*time.Millisecond, func() bool { return false })
+	timer.Stop()
+	if count == 0 || count > 200 {
+		t.Error("zero period timer fired", count, "times")
+	}
+	node.spinnersMutex.Lock()
+	if len(node.timers) != 0 {
+		t.Error("node still holds", len(node.timers), "timers")
+	}
+	node.spinnersMutex.Unlock()
+}
@@ -0,0 +1,187 @@
+package ros
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	TransportTCPROS = "TCPROS"
+	TransportUDPROS = "UDPROS"
+
+	// DefaultMaxDatagramSize is the UDPROS datagram size requested by subscribers, header included.
+	DefaultMaxDatagramSize = 1500
+)
+
+// UDPROS datagram header: connection ID, opcode, message ID and block number.
+const (
+	udprosHeaderSize = 8
+	udprosOpData0    = 0 // First block of a message, block number holds the number of blocks.
+	udprosOpDataN    = 1 // Following blocks, block number holds the index of the block.
+	udprosOpPing     = 2
+	udprosOpErr      = 3
+)
+
+type udprosHeader struct {
+	ConnectionID uint32
+	Op           uint8
+	MessageID    uint8
+	Block        uint16
+}
+
+// writeUDPROSMessage splits the length prefixed message into datagrams of at most
+// maxDatagramSize bytes and writes them to conn.
+func writeUDPROSMessage(conn net.Conn, connectionID uint32, messageID uint8, maxDatagramSize int, msg []byte) error {
+	blockSize := maxDatagramSize - udprosHeaderSize
+	if blockSize <= 0 {
+		return fmt.Errorf("datagram size %d is too small", maxDatagramSize)
+	}
+	var payload bytes.Buffer
+	binary.Write(&payload, binary.LittleEndian, uint32(len(msg)))
+	payload.Write(msg)
+	data := payload.Bytes()
+	numBlocks := (len(data) + blockSize - 1) / blockSize
+	if numBlocks > 0xffff {
+		return fmt.Errorf("message of %d bytes is too large for UDPROS", len(msg))
+	}
+
+	var datagram bytes.Buffer
+	for i := 0; i < numBlocks; i++ {
+		h := udprosHeader{connectionID, udprosOpDataN, messageID, uint16(i)}
+		if i == 0 {
+			h.Op = udprosOpData0
+			h.Block = uint16(numBlocks)
+		}
+		end := (i + 1) * blockSize
+		if end > len(data) {
+			end = len(data)
+		}
+		datagram.Reset()
+		binary.Write(&datagram, binary.LittleEndian, &h)
+		datagram.Write(data[i*blockSize : end])
+		if _, err := conn.Write(datagram.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// udprosReassembler collects the blocks of a message. A message is dropped when
+// any of its blocks is lost, since UDPROS doesn't retransmit.
+type udprosReassembler struct {
+	connectionID uint32
+	messageID    uint8
+	blocks       [][]byte
+	received     int
+	dropped      int // Incomplete messages discarded by the last call to add.
+}
+
+// add consumes a datagram and returns the message once all of its blocks were received.
+func (r *udprosReassembler) add(datagram []byte) ([]byte, error) {
+	if len(datagram) < udprosHeaderSize {
+		return nil, fmt.Errorf("UDPROS datagram is too short")
+	}
+	var h udprosHeader
+	if err := binary.Read(bytes.NewReader(datagram[:udprosHeaderSize]), binary.LittleEndian, &h); err != nil {
+		return nil, err
+	}
+	if h.ConnectionID != r.connectionID {
+		return nil, nil
+	}
+	block := datagram[udprosHeaderSize:]
+	r.dropped = 0
+	switch h.Op {
+	case udprosOpData0:
+		if r.blocks != nil {
+			r.dropped++
+		}
+		if h.Block == 0 {
+			return nil, fmt.Errorf("UDPROS message without blocks")
+		}
+		r.messageID = h.MessageID
+		r.blocks = make([][]byte, int(h.Block))
+		r.blocks[0] = append([]byte(nil), block...)
+		r.received = 1
+	case udprosOpDataN:
+		if r.blocks == nil || h.MessageID != r.messageID || int(h.Block) >= len(r.blocks) {
+			// A block of a message whose first block was lost.
+			return nil, nil
+		}
+		if r.blocks[h.Block] == nil {
+			r.blocks[h.Block] = append([]byte(nil), block...)
+			r.received++
+		}
+	default:
+		return nil, nil
+	}
+	if r.received < len(r.blocks) {
+		return nil, nil
+	}
+
+	data := bytes.Join(r.blocks, nil)
+	r.blocks = nil
+	r.received = 0
+	if len(data) < 4 {
+		return nil, fmt.Errorf("UDPROS message is too short")
+	}
+	size := binary.LittleEndian.Uint32(data[:4])
+	if int(size) != len(data)-4 {
+		return nil, fmt.Errorf("UDPROS message size mismatch: %d vs %d", size, len(data)-4)
+	}
+	return data[4:], nil
+}
+
+func startRemotePublisherUDPConn(logger Logger,
+	conn *net.UDPConn, connectionID uint32, maxDatagramSize int,
+	pubURI string, topic string, resHeaderMap map[string]string,
+	stats *connectionStats,
+	msgChan chan messageEvent,
+	quitChan chan struct{},
+	disconnectedChan chan string) {
+	logger.Debug("startRemotePublisherUDPConn()")
+
+	defer func() {
+		conn.Close()
+		stats.close()
+		logger.Debug("startRemotePublisherUDPConn() exit")
+	}()
+
+	event := MessageEvent{
+		PublisherName:    resHeaderMap["callerid"],
+		ConnectionHeader: resHeaderMap,
+	}
+	reassembler := udprosReassembler{connectionID: connectionID}
+	datagram := make([]byte, maxDatagramSize)
+	for {
+		select {
+		case <-quitChan:
+			return
+		default:
+			conn.SetReadDeadline(time.Now().Add(1000 * time.Millisecond))
+			n, err := conn.Read(datagram)
+			if err != nil {
+				if neterr, ok := err.(net.Error); ok && neterr.Timeout() {
+					continue
+				}
+				logger.Errorf("Failed to read a UDPROS datagram from %s on topic %s: %v", pubURI, topic, err)
+				disconnectedChan <- pubURI
+				return
+			}
+			msg, err := reassembler.add(datagram[:n])
+			stats.addDrops(reassembler.dropped)
+			if err != nil {
+				logger.Warnf("Dropped a UDPROS message on topic %s: %v", topic, err)
+				stats.addDrops(1)
+				continue
+			}
+			if msg != nil {
+				event.ReceiptTime = time.Now()
+				stats.addMessage(4 + len(msg))
+				msgChan <- messageEvent{bytes: msg, event: event}
+			}
+		}
+	}
+}
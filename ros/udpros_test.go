@@ -0,0 +1,106 @@
+package ros
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUDPROSFragmentation(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	conn, err := net.Dial("udp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	msg := bytes.Repeat([]byte("0123456789"), 10)
+	if err := writeUDPROSMessage(conn, 7, 3, 32, msg); err != nil {
+		t.Fatal(err)
+	}
+	r := udprosReassembler{connectionID: 7}
+	datagram := make([]byte, 32)
+	var result []byte
+	for i := 0; result == nil; i++ {
+		pc.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := pc.ReadFrom(datagram)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// (4 + 100) bytes in blocks of 24 bytes.
+		if i >= 5 {
+			t.Fatal("too many datagrams")
+		}
+		if result, err = r.add(datagram[:n]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(result, msg) {
+		t.Error(result)
+	}
+}
+
+func TestUDPROSReassemblerDropsIncomplete(t *testing.T) {
+	conn := &datagramRecorder{}
+	writeUDPROSMessage(conn, 1, 0, 16, []byte("a message split in blocks"))
+	r := udprosReassembler{connectionID: 1}
+	// Lose the first block.
+	for _, d := range conn.datagrams[1:] {
+		if msg, err := r.add(d); msg != nil || err != nil {
+			t.Error(msg, err)
+		}
+	}
+	// Datagrams of other connections are ignored.
+	r = udprosReassembler{connectionID: 2}
+	for _, d := range conn.datagrams {
+		if msg, err := r.add(d); msg != nil || err != nil {
+			t.Error(msg, err)
+		}
+	}
+}
+
+// datagramRecorder is a net.Conn which keeps the written datagrams.
+type datagramRecorder struct {
+	net.Conn
+	datagrams [][]byte
+}
+
+func (c *datagramRecorder) Write(b []byte) (int, error) {
+	c.datagrams = append(c.datagrams, append([]byte(nil), b...))
+	return len(b), nil
+}
+
+func TestUDPROSSubscriber(t *testing.T) {
+	m := newTestMaster(t)
+	defer m.Shutdown()
+	node := newTestNode(t, m, "/udpros_test")
+	defer node.Shutdown()
+
+	pub := node.NewPublisher("/udp", msgTestMessage)
+	var received []string
+	var header map[string]string
+	node.NewSubscriber("/udp", msgTestMessage, func(msg *testMessage, event MessageEvent) {
+		received = append(received, msg.Data)
+		header = event.ConnectionHeader
+	}, SubscriberTransportHints(TransportUDPROS), SubscriberMaxDatagramSize(64))
+	// Let the subscriber connect.
+	time.Sleep(500 * time.Millisecond)
+
+	long := strings.Repeat("x", 200)
+	pub.Publish(&testMessage{long})
+	if !spinUntil(node, 5*time.Second, func() bool { return len(received) > 0 }) {
+		t.Fatal("message was not received")
+	}
+	if received[0] != long {
+		t.Error(received)
+	}
+	if header["type"] != msgTestMessage.Name() || header["callerid"] != "/udpros_test" {
+		t.Error(header)
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/fetchrobotics/rosgo/master"
+)
+
+func main() {
+	m, err := master.NewMaster(":11311")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+	defer m.Shutdown()
+	fmt.Printf("ROS_MASTER_URI=%s\n", m.URI())
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	<-interrupt
+}